@@ -0,0 +1,342 @@
+package provider
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrMissingConfig is returned for required configuration keys that aren't set.
+var ErrMissingConfig = errors.New("required key is missing")
+
+// ConfigFieldError describes why a single configuration key couldn't be decoded.
+type ConfigFieldError struct {
+	// Key is the configuration (or secret) key, including any nested prefix
+	Key string
+	// Field is the path of the struct field the key decodes into, ex: Database.Port
+	Field string
+	Err   error
+}
+
+func (e *ConfigFieldError) Error() string {
+	return fmt.Sprintf("%s (%s): %v", e.Key, e.Field, e.Err)
+}
+
+func (e *ConfigFieldError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError aggregates every error found while decoding configuration, so that all
+// problems can be reported at once.
+type ConfigError struct {
+	Errors []*ConfigFieldError
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration: %d error(s)", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+func (e *ConfigError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// DecodeLinkConfig decodes the config and secrets of the provider's side of a link: the
+// source ones if wp is the source of the link, the target ones otherwise. See DecodeConfig
+// for the supported struct tags.
+func DecodeLinkConfig[T any](wp *WasmcloudProvider, link InterfaceLinkDefinition) (T, error) {
+	if link.SourceID == wp.Id {
+		return DecodeSourceLinkConfig[T](link)
+	}
+	return DecodeTargetLinkConfig[T](link)
+}
+
+// DecodeSourceLinkConfig decodes the source config and secrets of a link, used when the
+// provider is the source of the link. See DecodeConfig for the supported struct tags.
+func DecodeSourceLinkConfig[T any](link InterfaceLinkDefinition) (T, error) {
+	return DecodeConfig[T](link.SourceConfig, link.SourceSecrets)
+}
+
+// DecodeTargetLinkConfig decodes the target config and secrets of a link, used when the
+// provider is the target of the link. See DecodeConfig for the supported struct tags.
+func DecodeTargetLinkConfig[T any](link InterfaceLinkDefinition) (T, error) {
+	return DecodeConfig[T](link.TargetConfig, link.TargetSecrets)
+}
+
+// DecodeProviderConfig decodes the provider configuration from the host data. Config values
+// take precedence over EnvValues, and fields with the "secret" option are read from Secrets
+// only. See DecodeConfig for the supported struct tags.
+func DecodeProviderConfig[T any](hostData HostData) (T, error) {
+	config := make(map[string]string, len(hostData.EnvValues)+len(hostData.Config))
+	for k, v := range hostData.EnvValues {
+		config[k] = v
+	}
+	for k, v := range hostData.Config {
+		config[k] = v
+	}
+
+	return DecodeConfig[T](config, hostData.Secrets)
+}
+
+// DecodeConfig decodes configuration and secrets into a struct of type T. Fields are
+// configured with struct tags:
+//
+//	type Config struct {
+//		URL      string        `config:"url,required"`
+//		Timeout  time.Duration `config:"timeout" default:"5s"`
+//		Tags     []string      `config:"tags"`
+//		Password string        `config:"password,secret"`
+//		Database struct {
+//			Host string `config:"host" default:"localhost"`
+//		} `config:"db"`
+//	}
+//
+// The `config` tag sets the key name, defaulting to the field name in snake_case, or
+// skips the field with "-". The "required" option fails decoding when the key is missing
+// and has no default. The "secret" option reads the value from secrets instead of config.
+// The `default` tag sets the value used when the key is missing, for secrets too.
+//
+// Supported field types are strings, bools, integers, floats, time.Duration, types
+// implementing encoding.TextUnmarshaler, SecretValue, pointers to those and slices of
+// those, decoded from comma separated values. []byte fields receive the raw value.
+// Nested structs read keys prefixed with their own key and an underscore (ex: db_host),
+// which can be changed with the `prefix` tag.
+//
+// All invalid or missing keys are reported together in a *ConfigError.
+func DecodeConfig[T any](config map[string]string, secrets map[string]SecretValue) (T, error) {
+	var out T
+	v := reflect.ValueOf(&out).Elem()
+	if v.Kind() != reflect.Struct {
+		return out, fmt.Errorf("cannot decode configuration into %s, expected a struct", v.Type())
+	}
+
+	d := configDecoder{config: config, secrets: secrets}
+	d.decodeStruct(v, "", "")
+	if len(d.errors) > 0 {
+		return out, &ConfigError{Errors: d.errors}
+	}
+
+	return out, nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	secretValueType     = reflect.TypeOf(SecretValue{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type configDecoder struct {
+	config  map[string]string
+	secrets map[string]SecretValue
+	errors  []*ConfigFieldError
+}
+
+func (d *configDecoder) fail(key string, field string, err error) {
+	d.errors = append(d.errors, &ConfigFieldError{Key: key, Field: field, Err: err})
+}
+
+func (d *configDecoder) decodeStruct(v reflect.Value, keyPrefix string, fieldPrefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("config")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+		var required, secret bool
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "required":
+				required = true
+			case "secret":
+				secret = true
+			}
+		}
+
+		key := keyPrefix + name
+		fieldPath := fieldPrefix + field.Name
+		fv := v.Field(i)
+
+		if isNestedConfig(field.Type) {
+			prefix, ok := field.Tag.Lookup("prefix")
+			if !ok {
+				prefix = name + "_"
+			}
+			d.decodeStruct(fv, keyPrefix+prefix, fieldPath+".")
+			continue
+		}
+
+		defaultValue, hasDefault := field.Tag.Lookup("default")
+		if secret {
+			d.decodeSecret(fv, key, fieldPath, required, defaultValue, hasDefault)
+			continue
+		}
+
+		value, ok := d.config[key]
+		if !ok {
+			value, ok = defaultValue, hasDefault
+		}
+		if !ok {
+			if required {
+				d.fail(key, fieldPath, ErrMissingConfig)
+			}
+			continue
+		}
+
+		if err := setConfigValue(fv, value); err != nil {
+			d.fail(key, fieldPath, err)
+		}
+	}
+}
+
+func (d *configDecoder) decodeSecret(v reflect.Value, key string, fieldPath string, required bool, defaultValue string, hasDefault bool) {
+	secret, ok := d.secrets[key]
+	if !ok && hasDefault {
+		secret, ok = NewSecretString(defaultValue), true
+	}
+	if !ok {
+		if required {
+			d.fail(key, fieldPath, ErrMissingConfig)
+		}
+		return
+	}
+
+	if v.Type() == secretValueType {
+		v.Set(reflect.ValueOf(secret))
+		return
+	}
+
+	raw := secret.String.Reveal()
+	if secret.IsBytes() {
+		raw = string(secret.Bytes.Reveal())
+	}
+	if err := setConfigValue(v, raw); err != nil {
+		d.fail(key, fieldPath, err)
+	}
+}
+
+// isNestedConfig reports whether a field type is a struct whose fields are decoded
+// individually, rather than a single value.
+func isNestedConfig(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct &&
+		t != secretValueType &&
+		!reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func setConfigValue(v reflect.Value, value string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setConfigValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(value))
+			return nil
+		}
+		if strings.TrimSpace(value) == "" {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+			return nil
+		}
+		items := strings.Split(value, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setConfigValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", value)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
+
+// snakeCase converts a Go field name to snake_case, keeping acronyms together,
+// ex: MaxConns -> max_conns, DBHost -> db_host.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+type testDatabaseConfig struct {
+	Host string `config:"host" default:"localhost"`
+	Port int    `config:"port,required"`
+}
+
+type testLinkConfig struct {
+	URL         string        `config:"url,required"`
+	Timeout     time.Duration `config:"timeout" default:"5s"`
+	MaxConns    uint16
+	Verbose     bool    `config:"verbose"`
+	Ratio       float64 `config:"ratio"`
+	Tags        []string
+	Ports       []int              `config:"ports"`
+	Retries     *int               `config:"retries"`
+	Address     net.IP             `config:"address"`
+	Password    string             `config:"password,secret"`
+	Certificate []byte             `config:"certificate,secret"`
+	Token       SecretValue        `config:"token,secret"`
+	Database    testDatabaseConfig `config:"db"`
+	Cache       testDatabaseConfig `prefix:"cache."`
+	Ignored     string             `config:"-"`
+	unexported  string
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config := map[string]string{
+		"url":        "nats://127.0.0.1:4222",
+		"timeout":    "1m30s",
+		"max_conns":  "42",
+		"verbose":    "true",
+		"ratio":      "0.5",
+		"tags":       "a, b ,c",
+		"ports":      "80,443",
+		"retries":    "3",
+		"address":    "10.0.0.1",
+		"db_port":    "5432",
+		"cache.host": "cache",
+		"cache.port": "6379",
+		"Ignored":    "ignored",
+		"ignored":    "ignored",
+		"unexported": "ignored",
+	}
+	secrets := map[string]SecretValue{
+		"password":    {String: SecretStringValue{value: "hunter2"}},
+		"certificate": {Bytes: SecretBytesValue{value: []byte{0, 1, 2}}},
+		"token":       {String: SecretStringValue{value: "token"}},
+	}
+
+	cfg, err := DecodeConfig[testLinkConfig](config, secrets)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	retries := 3
+	expected := testLinkConfig{
+		URL:         "nats://127.0.0.1:4222",
+		Timeout:     90 * time.Second,
+		MaxConns:    42,
+		Verbose:     true,
+		Ratio:       0.5,
+		Tags:        []string{"a", "b", "c"},
+		Ports:       []int{80, 443},
+		Retries:     &retries,
+		Address:     net.ParseIP("10.0.0.1"),
+		Password:    "hunter2",
+		Certificate: []byte{0, 1, 2},
+		Token:       SecretValue{String: SecretStringValue{value: "token"}},
+		Database:    testDatabaseConfig{Host: "localhost", Port: 5432},
+		Cache:       testDatabaseConfig{Host: "cache", Port: 6379},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected config %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestDecodeConfigDefaults(t *testing.T) {
+	cfg, err := DecodeConfig[testLinkConfig](map[string]string{
+		"url":        "nats://127.0.0.1:4222",
+		"db_port":    "5432",
+		"cache.port": "6379",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	if cfg.Timeout != 5*time.Second {
+		t.Errorf("Expected default timeout of 5s, got %s", cfg.Timeout)
+	}
+	if cfg.Retries != nil {
+		t.Errorf("Expected retries to be nil, got %d", *cfg.Retries)
+	}
+	if cfg.Tags != nil || cfg.Password != "" || cfg.Token.String.Reveal() != "" {
+		t.Errorf("Expected missing keys to keep zero values, got %+v", cfg)
+	}
+
+	type secretConfig struct {
+		Token  string      `config:"token,secret" default:"dev-token"`
+		APIKey SecretValue `config:"api_key,secret,required" default:"dev-key"`
+	}
+	secrets, err := DecodeConfig[secretConfig](nil, nil)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	if secrets.Token != "dev-token" || secrets.APIKey.String.Reveal() != "dev-key" {
+		t.Errorf("Expected the defaults of missing secrets, got %+v", secrets)
+	}
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	_, err := DecodeConfig[testLinkConfig](map[string]string{
+		"timeout":    "soon",
+		"max_conns":  "100000",
+		"verbose":    "maybe",
+		"ports":      "80,https",
+		"address":    "not-an-ip",
+		"cache.port": "6379",
+	}, nil)
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a *ConfigError, got: %v", err)
+	}
+
+	keys := make([]string, len(configErr.Errors))
+	for i, fieldErr := range configErr.Errors {
+		keys[i] = fieldErr.Key
+	}
+	expected := []string{"url", "timeout", "max_conns", "verbose", "ports", "address", "db_port"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected errors for %v, got %v", expected, keys)
+	}
+
+	if !errors.Is(err, ErrMissingConfig) {
+		t.Error("Expected err to wrap ErrMissingConfig")
+	}
+	if configErr.Errors[6].Field != "Database.Port" {
+		t.Errorf("Expected field path Database.Port, got %s", configErr.Errors[6].Field)
+	}
+	for _, key := range expected {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected report to mention %q, got:\n%s", key, err)
+		}
+	}
+}
+
+func TestDecodeLinkConfig(t *testing.T) {
+	type config struct {
+		Bucket string `config:"bucket,required"`
+		Key    string `config:"key,secret,required"`
+	}
+
+	link := InterfaceLinkDefinition{
+		SourceConfig:  map[string]string{"bucket": "source"},
+		TargetConfig:  map[string]string{"bucket": "target"},
+		SourceSecrets: map[string]SecretValue{"key": {String: SecretStringValue{value: "source-key"}}},
+		TargetSecrets: map[string]SecretValue{"key": {String: SecretStringValue{value: "target-key"}}},
+	}
+
+	source, err := DecodeSourceLinkConfig[config](link)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	if source.Bucket != "source" || source.Key != "source-key" {
+		t.Errorf("Expected source config, got %+v", source)
+	}
+
+	target, err := DecodeTargetLinkConfig[config](link)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	if target.Bucket != "target" || target.Key != "target-key" {
+		t.Errorf("Expected target config, got %+v", target)
+	}
+
+	wp := &WasmcloudProvider{Id: "provider"}
+	link.SourceID = "provider"
+	if cfg, err := DecodeLinkConfig[config](wp, link); err != nil || cfg.Bucket != "source" {
+		t.Errorf("Expected the source config for the source provider, got %+v, %v", cfg, err)
+	}
+	link.SourceID, link.Target = "component", "provider"
+	if cfg, err := DecodeLinkConfig[config](wp, link); err != nil || cfg.Key != "target-key" {
+		t.Errorf("Expected the target config for the target provider, got %+v, %v", cfg, err)
+	}
+
+	if _, err := DecodeConfig[string](nil, nil); err == nil {
+		t.Error("Expected decoding into a non-struct type to fail")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"URL":      "url",
+		"MaxConns": "max_conns",
+		"DBHost":   "db_host",
+		"Port2":    "port2",
+		"HTTPPort": "http_port",
+	} {
+		if got := snakeCase(name); got != expected {
+			t.Errorf("Expected %s to be %s, got %s", name, expected, got)
+		}
+	}
+}
+
+func TestDecodeProviderConfig(t *testing.T) {
+	type config struct {
+		Region   string `config:"region"`
+		Endpoint string `config:"endpoint"`
+		Bucket   string `config:"bucket,required"`
+		Key      string `config:"key,secret"`
+	}
+
+	cfg, err := DecodeProviderConfig[config](HostData{
+		EnvValues: map[string]string{"region": "env-region", "endpoint": "env-endpoint", "key": "env-key"},
+		Config:    map[string]string{"region": "config-region", "bucket": "config-bucket"},
+		Secrets:   map[string]SecretValue{"key": {String: SecretStringValue{value: "secret-key"}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	expected := config{Region: "config-region", Endpoint: "env-endpoint", Bucket: "config-bucket", Key: "secret-key"}
+	if cfg != expected {
+		t.Errorf("Expected config %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestProviderConfigOption(t *testing.T) {
+	type config struct {
+		Bucket  string        `config:"bucket,required"`
+		Timeout time.Duration `config:"timeout" default:"1s"`
+	}
+
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    startNatsServer(t, &server.Options{}),
+		Config:           map[string]string{"bucket": "data"},
+	}
+	var cfg config
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData), ProviderConfig(&cfg))
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	wp.NatsConnection().Close()
+	if cfg.Bucket != "data" || cfg.Timeout != time.Second {
+		t.Errorf("Expected decoded config, got %+v", cfg)
+	}
+
+	hostData.Config = map[string]string{"timeout": "never"}
+	_, err = NewWithHostDataSource(hostDataSource(t, hostData), ProviderConfig(&cfg))
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Errors) != 2 {
+		t.Errorf("Expected provider creation to fail with 2 config errors, got: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"errors"
+	"log/slog"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// natsAuthOptions returns the options needed to authenticate with the lattice using the
+// credentials provided by the host. When both a user JWT and seed are present, decentralized
+// (JWT) auth is used. When only a seed is present, the provider authenticates with its nkey.
+func natsAuthOptions(hostData HostData) ([]nats.Option, error) {
+	userJWT := hostData.LatticeRPCUserJWT
+	userSeed := hostData.LatticeRPCUserSeed.Reveal()
+
+	switch {
+	case userJWT != "" && userSeed != "":
+		return []nats.Option{nats.UserJWTAndSeed(userJWT, userSeed)}, nil
+	case userSeed != "":
+		kp, err := nkeys.FromSeed([]byte(userSeed))
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := kp.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		return []nats.Option{nats.Nkey(publicKey, kp.Sign)}, nil
+	case userJWT != "":
+		return nil, errors.New("lattice rpc user jwt was provided without a seed")
+	}
+
+	return nil, nil
+}
+
+// natsConnectionHandlers returns the options used to report lattice connection state
+// changes to the provider logger and any user provided callbacks. nats.go only keeps the last
+// handlers set, so the ones set in opts, passed with NatsOptions, are called after the provider
+// ones instead of being replaced.
+func (wp *WasmcloudProvider) natsConnectionHandlers(opts []nats.Option) []nats.Option {
+	var user nats.Options
+	for _, opt := range opts {
+		// Failing options are reported when connecting
+		_ = opt(&user)
+	}
+
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			// Closing the connection also triggers a disconnect, which is reported by the closed handler
+			if !nc.IsClosed() {
+				wp.Logger.Warn("disconnected from lattice", slog.Any("error", err))
+				wp.disconnectedFunc(err)
+			}
+			if user.DisconnectedErrCB != nil {
+				user.DisconnectedErrCB(nc, err)
+			} else if user.DisconnectedCB != nil {
+				user.DisconnectedCB(nc)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			wp.Logger.Info("reconnected to lattice", "url", nc.ConnectedUrlRedacted())
+			wp.reconnectedFunc()
+			if user.ReconnectedCB != nil {
+				user.ReconnectedCB(nc)
+			}
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			wp.Logger.Info("lattice connection closed")
+			close(wp.connectionClosed)
+			wp.connectionClosedFunc()
+			if user.ClosedCB != nil {
+				user.ClosedCB(nc)
+			}
+			if wp.exitReason() == "" {
+				// The connection was closed outside of a shutdown, ex: after running out
+				// of reconnect attempts, so the provider can't keep running
+				go func() { _ = wp.shutdown(ExitReasonConnectionLost, nil) }()
+			}
+		}),
+	}
+}
+
+// ConnectionState returns the current state of the lattice connection.
+func (wp *WasmcloudProvider) ConnectionState() nats.Status {
+	return wp.natsConnection.Status()
+}
@@ -0,0 +1,398 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"go.opentelemetry.io/otel"
+)
+
+func TestNatsAuthOptions(t *testing.T) {
+	user, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	seed, err := user.Seed()
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	type test struct {
+		name     string
+		hostData HostData
+		options  int
+		wantErr  bool
+	}
+
+	tests := []test{
+		{
+			name:     "No credentials",
+			hostData: HostData{},
+			options:  0,
+		},
+		{
+			name:     "JWT and seed",
+			hostData: HostData{LatticeRPCUserJWT: "jwt", LatticeRPCUserSeed: RedactedString(seed)},
+			options:  1,
+		},
+		{
+			name:     "Seed only",
+			hostData: HostData{LatticeRPCUserSeed: RedactedString(seed)},
+			options:  1,
+		},
+		{
+			name:     "Invalid seed",
+			hostData: HostData{LatticeRPCUserSeed: "not-a-seed"},
+			wantErr:  true,
+		},
+		{
+			name:     "JWT without seed",
+			hostData: HostData{LatticeRPCUserJWT: "jwt"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		options, err := natsAuthOptions(tc.hostData)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got nil", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected err to be nil, got: %v", tc.name, err)
+		}
+		if len(options) != tc.options {
+			t.Errorf("%s: expected %d options, got %d", tc.name, tc.options, len(options))
+		}
+	}
+}
+
+func TestConnectWithUserJWTAndSeed(t *testing.T) {
+	operator, err := nkeys.CreateOperator()
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	operatorPublicKey, _ := operator.PublicKey()
+	operatorClaims := jwt.NewOperatorClaims(operatorPublicKey)
+	if _, err := operatorClaims.Encode(operator); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	account, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	accountPublicKey, _ := account.PublicKey()
+	accountJWT, err := jwt.NewAccountClaims(accountPublicKey).Encode(operator)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	user, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	userPublicKey, _ := user.PublicKey()
+	userSeed, _ := user.Seed()
+	userJWT, err := jwt.NewUserClaims(userPublicKey).Encode(account)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	resolver := &server.MemAccResolver{}
+	if err := resolver.Store(accountPublicKey, accountJWT); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	url := startNatsServer(t, &server.Options{
+		TrustedOperators: []*jwt.OperatorClaims{operatorClaims},
+		AccountResolver:  resolver,
+	})
+
+	hostData := HostData{
+		ProviderKey:        "provider",
+		LatticeRPCPrefix:   "default",
+		LatticeRPCURL:      url,
+		LatticeRPCUserJWT:  userJWT,
+		LatticeRPCUserSeed: RedactedString(userSeed),
+	}
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData))
+	if err != nil {
+		t.Fatalf("Expected provider to connect with user JWT and seed, got: %v", err)
+	}
+	wp.NatsConnection().Close()
+
+	hostData.LatticeRPCUserJWT = ""
+	hostData.LatticeRPCUserSeed = ""
+	if _, err := NewWithHostDataSource(hostDataSource(t, hostData)); err == nil {
+		t.Error("Expected provider to fail to connect without credentials")
+	}
+}
+
+func TestConnectWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := writeCertificate(t, dir, "ca", nil, nil)
+	writeCertificate(t, dir, "server", caCert, caKey)
+	writeCertificate(t, dir, "client", caCert, caKey)
+
+	tlsConfig, err := server.GenTLSConfig(&server.TLSConfigOpts{
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server-key.pem"),
+		CaFile:   filepath.Join(dir, "ca.pem"),
+		Verify:   true,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	url := startNatsServer(t, &server.Options{
+		TLS:       true,
+		TLSVerify: true,
+		TLSConfig: tlsConfig,
+	})
+
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    url,
+	}
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData),
+		TLSRootCAs(filepath.Join(dir, "ca.pem")),
+		TLSClientCert(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")),
+	)
+	if err != nil {
+		t.Fatalf("Expected provider to connect with TLS, got: %v", err)
+	}
+	wp.NatsConnection().Close()
+
+	if _, err := NewWithHostDataSource(hostDataSource(t, hostData), TLSRootCAs(filepath.Join(dir, "ca.pem"))); err == nil {
+		t.Error("Expected provider to fail to connect without a client certificate")
+	}
+}
+
+func TestConnectFailureShutsDownTelemetry(t *testing.T) {
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    "nats://127.0.0.1:1",
+		OtelConfig: OtelConfig{
+			EnableTraces:   true,
+			TracesEndpoint: "http://127.0.0.1:1/v1/traces",
+		},
+	}
+	if _, err := NewWithHostDataSource(hostDataSource(t, hostData)); err == nil {
+		t.Fatal("Expected provider to fail to connect")
+	}
+
+	// A tracer provider that was shut down only creates non recording spans
+	_, span := otel.GetTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+	if span.IsRecording() {
+		t.Error("Expected the tracer provider to be shut down")
+	}
+}
+
+func TestConnectionLifecycle(t *testing.T) {
+	ns := newNatsServer(t, &server.Options{})
+
+	disconnected := make(chan struct{}, 1)
+	reconnected := make(chan struct{}, 1)
+	closed := make(chan struct{}, 1)
+	// Handlers passed with NatsOptions are called as well
+	natsDisconnected := make(chan struct{}, 1)
+	natsReconnected := make(chan struct{}, 1)
+	natsClosed := make(chan struct{}, 1)
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    ns.ClientURL(),
+	}
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData),
+		ReconnectWait(10*time.Millisecond),
+		MaxReconnects(-1),
+		ConnectionDisconnected(func(error) { disconnected <- struct{}{} }),
+		ConnectionReconnected(func() { reconnected <- struct{}{} }),
+		ConnectionClosed(func() { closed <- struct{}{} }),
+		NatsOptions(
+			nats.DisconnectErrHandler(func(*nats.Conn, error) {
+				select {
+				case natsDisconnected <- struct{}{}:
+				default:
+				}
+			}),
+			nats.ReconnectHandler(func(*nats.Conn) { natsReconnected <- struct{}{} }),
+			nats.ClosedHandler(func(*nats.Conn) { natsClosed <- struct{}{} }),
+		),
+	)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	if state := wp.ConnectionState(); state != nats.CONNECTED {
+		t.Errorf("Expected connection state to be %s, got %s", nats.CONNECTED, state)
+	}
+	if hc := wp.healthCheck(context.Background()); !hc.Healthy {
+		t.Errorf("Expected provider to be healthy, got: %+v", hc)
+	}
+
+	port := ns.Addr().(*net.TCPAddr).Port
+	ns.Shutdown()
+	waitFor(t, disconnected, "disconnect")
+	waitFor(t, natsDisconnected, "nats disconnect handler")
+
+	if state := wp.ConnectionState(); state != nats.RECONNECTING {
+		t.Errorf("Expected connection state to be %s, got %s", nats.RECONNECTING, state)
+	}
+	if hc := wp.healthCheck(context.Background()); hc.Healthy {
+		t.Errorf("Expected provider to be unhealthy while reconnecting, got: %+v", hc)
+	}
+
+	newNatsServer(t, &server.Options{Port: port})
+	waitFor(t, reconnected, "reconnect")
+	waitFor(t, natsReconnected, "nats reconnect handler")
+
+	if hc := wp.healthCheck(context.Background()); !hc.Healthy {
+		t.Errorf("Expected provider to be healthy after reconnecting, got: %+v", hc)
+	}
+
+	wp.NatsConnection().Close()
+	waitFor(t, closed, "close")
+	waitFor(t, natsClosed, "nats closed handler")
+	if state := wp.ConnectionState(); state != nats.CLOSED {
+		t.Errorf("Expected connection state to be %s, got %s", nats.CLOSED, state)
+	}
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, event string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", event)
+	}
+}
+
+// newTestProvider starts an in-process NATS server and returns a provider connected to it.
+func newTestProvider(t *testing.T, options ...ProviderHandler) *WasmcloudProvider {
+	t.Helper()
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    startNatsServer(t, &server.Options{}),
+	}
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData), options...)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	t.Cleanup(wp.NatsConnection().Close)
+
+	return wp
+}
+
+// startNatsServer starts an in-process NATS server on a random port and returns its client URL.
+func startNatsServer(t *testing.T, opts *server.Options) string {
+	t.Helper()
+	return newNatsServer(t, opts).ClientURL()
+}
+
+// newNatsServer starts an in-process NATS server, on a random port unless one is set.
+func newNatsServer(t *testing.T, opts *server.Options) *server.Server {
+	t.Helper()
+	opts.Host = "127.0.0.1"
+	if opts.Port == 0 {
+		opts.Port = server.RANDOM_PORT
+	}
+	opts.NoLog = true
+	opts.NoSigs = true
+
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("failed to create nats server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server did not become ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	return ns
+}
+
+// hostDataSource encodes host data the same way the wasmCloud host sends it on stdin.
+func hostDataSource(t *testing.T, hostData HostData) io.Reader {
+	t.Helper()
+	encoded, err := EncodeHostData(hostData)
+	if err != nil {
+		t.Fatalf("failed to encode host data: %v", err)
+	}
+	return bytes.NewBufferString(encoded)
+}
+
+// writeCertificate writes a PEM encoded certificate and key for 127.0.0.1 to dir. If parent is
+// nil, the certificate is a self-signed CA.
+func writeCertificate(t *testing.T, dir string, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent = template
+		parentKey = key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), certPem, 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPem, 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	return cert, key
+}
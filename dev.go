@@ -0,0 +1,466 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultDevHostID              = "dev-host"
+	defaultDevProviderKey         = "dev-provider"
+	defaultDevLatticeRPCPrefix    = "default"
+	defaultDevLinksReloadInterval = time.Second
+)
+
+// DevConfig describes a provider running without a wasmCloud host, next to a local NATS
+// server. See NewDev and ParseDevConfig.
+type DevConfig struct {
+	// Defaults to "dev-host"
+	HostID string `yaml:"host_id"`
+	// Defaults to "dev-provider"
+	ProviderKey string `yaml:"provider_key"`
+	// Defaults to "default"
+	LatticeRPCPrefix string `yaml:"lattice"`
+	// Defaults to nats://127.0.0.1:4222
+	LatticeRPCURL     string            `yaml:"nats_url"`
+	LogLevel          Level             `yaml:"log_level"`
+	StructuredLogging bool              `yaml:"structured_logging"`
+	Config            map[string]string `yaml:"config"`
+	// Plaintext provider secrets
+	Secrets map[string]string `yaml:"secrets"`
+	Links   []DevLink         `yaml:"links"`
+	// LinksFile is a YAML or JSON file with a list of links under a "links" key. It is
+	// checked for changes every LinksReloadInterval (default 1s): new or modified links are
+	// put and removed ones deleted, like the host would.
+	LinksFile           string        `yaml:"links_file"`
+	LinksReloadInterval time.Duration `yaml:"links_reload_interval"`
+}
+
+// DevLink is a link definition with plaintext secrets. One of SourceID or Target is
+// required, the other one defaults to the provider. An empty Name defaults to "default".
+type DevLink struct {
+	SourceID      string            `yaml:"source_id"`
+	Target        string            `yaml:"target"`
+	Name          string            `yaml:"name"`
+	WitNamespace  string            `yaml:"wit_namespace"`
+	WitPackage    string            `yaml:"wit_package"`
+	Interfaces    []string          `yaml:"interfaces"`
+	SourceConfig  map[string]string `yaml:"source_config"`
+	TargetConfig  map[string]string `yaml:"target_config"`
+	SourceSecrets map[string]string `yaml:"source_secrets"`
+	TargetSecrets map[string]string `yaml:"target_secrets"`
+}
+
+// LoadDevConfig reads a DevConfig from a YAML or JSON file.
+func LoadDevConfig(path string) (DevConfig, error) {
+	var config DevConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := decodeDevYaml(data, &config); err != nil {
+		return config, fmt.Errorf("failed to decode dev config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseDevConfig builds a DevConfig from, in increasing order of precedence, the file given
+// with -dev-config or WASMCLOUD_DEV_CONFIG, WASMCLOUD_* environment variables and the
+// command line flags in args, usually os.Args[1:].
+//
+// The dev mode flags are defined on fs, which then parses args, so fs can define the
+// provider's own flags too, ex: flag.CommandLine. The remaining arguments are available with
+// fs.Args(). A nil fs uses a new flag set only accepting the dev mode flags.
+func ParseDevConfig(fs *flag.FlagSet, args []string) (DevConfig, error) {
+	if fs == nil {
+		fs = flag.NewFlagSet("provider", flag.ContinueOnError)
+	}
+	var flags DevConfig
+	configFile := fs.String("dev-config", os.Getenv("WASMCLOUD_DEV_CONFIG"), "YAML or JSON dev config file")
+	fs.StringVar(&flags.HostID, "host-id", "", "host ID")
+	fs.StringVar(&flags.ProviderKey, "provider-key", "", "provider key")
+	fs.StringVar(&flags.LatticeRPCPrefix, "lattice", "", "lattice name")
+	fs.StringVar(&flags.LatticeRPCURL, "nats-url", "", "NATS server URL")
+	fs.TextVar(&flags.LogLevel, "log-level", Info, "log level")
+	fs.BoolVar(&flags.StructuredLogging, "structured-logging", false, "log as JSON")
+	fs.Var((*keyValueFlag)(&flags.Config), "config", "provider config `key=value`, can be repeated")
+	fs.Var((*keyValueFlag)(&flags.Secrets), "secret", "provider secret `key=value`, can be repeated")
+	fs.StringVar(&flags.LinksFile, "links-file", "", "YAML or JSON links file, reloaded on change")
+	if err := fs.Parse(args); err != nil {
+		return DevConfig{}, err
+	}
+
+	var config DevConfig
+	if *configFile != "" {
+		var err error
+		config, err = LoadDevConfig(*configFile)
+		if err != nil {
+			return DevConfig{}, err
+		}
+	}
+
+	for name, field := range map[string]*string{
+		"WASMCLOUD_HOST_ID":      &config.HostID,
+		"WASMCLOUD_PROVIDER_KEY": &config.ProviderKey,
+		"WASMCLOUD_LATTICE":      &config.LatticeRPCPrefix,
+		"WASMCLOUD_NATS_URL":     &config.LatticeRPCURL,
+		"WASMCLOUD_LINKS_FILE":   &config.LinksFile,
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+	if value, ok := os.LookupEnv("WASMCLOUD_LOG_LEVEL"); ok {
+		if err := config.LogLevel.UnmarshalText([]byte(value)); err != nil {
+			return DevConfig{}, fmt.Errorf("invalid WASMCLOUD_LOG_LEVEL: %w", err)
+		}
+	}
+	if value, ok := os.LookupEnv("WASMCLOUD_STRUCTURED_LOGGING"); ok {
+		structured, err := strconv.ParseBool(value)
+		if err != nil {
+			return DevConfig{}, fmt.Errorf("invalid WASMCLOUD_STRUCTURED_LOGGING: %w", err)
+		}
+		config.StructuredLogging = structured
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host-id":
+			config.HostID = flags.HostID
+		case "provider-key":
+			config.ProviderKey = flags.ProviderKey
+		case "lattice":
+			config.LatticeRPCPrefix = flags.LatticeRPCPrefix
+		case "nats-url":
+			config.LatticeRPCURL = flags.LatticeRPCURL
+		case "log-level":
+			config.LogLevel = flags.LogLevel
+		case "structured-logging":
+			config.StructuredLogging = flags.StructuredLogging
+		case "config":
+			config.Config = mergeMaps(config.Config, flags.Config)
+		case "secret":
+			config.Secrets = mergeMaps(config.Secrets, flags.Secrets)
+		case "links-file":
+			config.LinksFile = flags.LinksFile
+		}
+	})
+	return config, nil
+}
+
+// HostData returns the host data the host would send a provider with this configuration.
+func (c DevConfig) HostData() HostData {
+	hostData := HostData{
+		HostID:            orDefault(c.HostID, defaultDevHostID),
+		ProviderKey:       orDefault(c.ProviderKey, defaultDevProviderKey),
+		LatticeRPCPrefix:  orDefault(c.LatticeRPCPrefix, defaultDevLatticeRPCPrefix),
+		LatticeRPCURL:     orDefault(c.LatticeRPCURL, nats.DefaultURL),
+		Config:            maps.Clone(c.Config),
+		Secrets:           plaintextSecrets(c.Secrets),
+		StructuredLogging: c.StructuredLogging,
+	}
+	if c.LogLevel != "" {
+		level := c.LogLevel
+		hostData.LogLevel = &level
+	}
+	return hostData
+}
+
+// NewDev creates a provider that runs without a wasmCloud host, connecting directly to the
+// NATS server of the configuration. The lattice topics the provider listens on are logged,
+// and the configured links are put when the provider starts. The links file, if any, is
+// watched once the provider started, until it shuts down.
+func NewDev(config DevConfig, options ...ProviderHandler) (*WasmcloudProvider, error) {
+	hostData := config.HostData()
+
+	links := make([]InterfaceLinkDefinition, 0, len(config.Links))
+	for i, link := range config.Links {
+		definition, err := link.definition(hostData.ProviderKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link %d: %w", i, err)
+		}
+		links = append(links, definition)
+	}
+
+	var fileLinks []InterfaceLinkDefinition
+	var fileData []byte
+	if config.LinksFile != "" {
+		var err error
+		fileData, err = os.ReadFile(config.LinksFile)
+		if err != nil {
+			return nil, err
+		}
+		fileLinks, err = decodeDevLinks(fileData, hostData.ProviderKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode links file %s: %w", config.LinksFile, err)
+		}
+	}
+
+	wp, err := NewWithHostData(hostData, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		_ = wp.updateProviderLinkMap(link)
+	}
+	for _, link := range fileLinks {
+		_ = wp.updateProviderLinkMap(link)
+	}
+
+	wp.Logger.Info("running without a host",
+		"host_id", hostData.HostID,
+		"lattice", hostData.LatticeRPCPrefix,
+		"nats_url", hostData.LatticeRPCURL,
+		"link_put_topic", wp.Topics.LATTICE_LINK_PUT,
+		"link_del_topic", wp.Topics.LATTICE_LINK_DEL,
+		"link_get_topic", wp.Topics.LATTICE_LINK_GET,
+		"health_topic", wp.Topics.LATTICE_HEALTH,
+		"shutdown_topic", wp.Topics.LATTICE_SHUTDOWN,
+	)
+
+	if config.LinksFile != "" {
+		interval := config.LinksReloadInterval
+		if interval <= 0 {
+			interval = defaultDevLinksReloadInterval
+		}
+		wp.linksWatcher = &devLinksWatcher{
+			path:     config.LinksFile,
+			interval: interval,
+			data:     fileData,
+			links:    fileLinks,
+		}
+	}
+	return wp, nil
+}
+
+// devLinksWatcher reloads the links file of a provider created with NewDev. It is started
+// once the provider runs, and stopped when it shuts down.
+type devLinksWatcher struct {
+	path     string
+	interval time.Duration
+	data     []byte
+	links    []InterfaceLinkDefinition
+
+	lock    sync.Mutex
+	stopped bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// start watches the links file in the background, unless the watcher was already started
+// or stopped.
+func (w *devLinksWatcher) start(wp *WasmcloudProvider) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stopped || w.done != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(wp.context)
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		w.watch(ctx, wp)
+	}()
+}
+
+// stop stops watching the links file, waiting for a reload in progress to complete.
+func (w *devLinksWatcher) stop() {
+	w.lock.Lock()
+	w.stopped = true
+	cancel, done := w.cancel, w.done
+	w.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// watch reloads the links file when its content changes, until ctx is done. Added or
+// modified links are put and removed ones deleted.
+func (w *devLinksWatcher) watch(ctx context.Context, wp *WasmcloudProvider) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(w.path)
+		if err != nil {
+			wp.Logger.Error("failed to read links file", "path", w.path, slog.Any("error", err))
+			continue
+		}
+		if bytes.Equal(data, w.data) {
+			continue
+		}
+		links, err := decodeDevLinks(data, wp.Id)
+		if err != nil {
+			wp.Logger.Error("failed to decode links file", "path", w.path, slog.Any("error", err))
+			continue
+		}
+		wp.Logger.Info("reloading links file", "path", w.path)
+		wp.reconcileLinks(w.links, links)
+		w.data, w.links = data, links
+	}
+}
+
+// reconcileLinks puts the links that were added or modified between old and new, and deletes
+// the ones that were removed.
+func (wp *WasmcloudProvider) reconcileLinks(old []InterfaceLinkDefinition, new []InterfaceLinkDefinition) {
+	previous := make(map[devLinkKey]InterfaceLinkDefinition, len(old))
+	for _, link := range old {
+		previous[newDevLinkKey(link)] = link
+	}
+
+	for _, link := range new {
+		key := newDevLinkKey(link)
+		existing, exists := previous[key]
+		delete(previous, key)
+		if exists && existing.equal(link) {
+			continue
+		}
+		if err := wp.putLink(link); err != nil {
+			wp.Logger.Error("failed to put link", "link", link.redacted(), slog.Any("error", err))
+		}
+	}
+
+	for _, link := range previous {
+		if err := wp.deleteLink(link); err != nil {
+			wp.Logger.Error("failed to delete link", "link", link.redacted(), slog.Any("error", err))
+		}
+	}
+}
+
+type devLinkKey struct {
+	sourceID     string
+	target       string
+	name         string
+	witNamespace string
+	witPackage   string
+}
+
+func newDevLinkKey(l InterfaceLinkDefinition) devLinkKey {
+	return devLinkKey{sourceID: l.SourceID, target: l.Target, name: l.Name, witNamespace: l.WitNamespace, witPackage: l.WitPackage}
+}
+
+func (l DevLink) definition(providerKey string) (InterfaceLinkDefinition, error) {
+	if l.SourceID == "" && l.Target == "" {
+		return InterfaceLinkDefinition{}, errors.New("either source_id or target is required")
+	}
+	return InterfaceLinkDefinition{
+		SourceID:      orDefault(l.SourceID, providerKey),
+		Target:        orDefault(l.Target, providerKey),
+		Name:          orDefault(l.Name, "default"),
+		WitNamespace:  l.WitNamespace,
+		WitPackage:    l.WitPackage,
+		Interfaces:    l.Interfaces,
+		SourceConfig:  l.SourceConfig,
+		TargetConfig:  l.TargetConfig,
+		SourceSecrets: plaintextSecrets(l.SourceSecrets),
+		TargetSecrets: plaintextSecrets(l.TargetSecrets),
+	}, nil
+}
+
+func decodeDevLinks(data []byte, providerKey string) ([]InterfaceLinkDefinition, error) {
+	var file struct {
+		Links []DevLink `yaml:"links"`
+	}
+	if err := decodeDevYaml(data, &file); err != nil {
+		return nil, err
+	}
+	links := make([]InterfaceLinkDefinition, 0, len(file.Links))
+	for i, link := range file.Links {
+		definition, err := link.definition(providerKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link %d: %w", i, err)
+		}
+		links = append(links, definition)
+	}
+	return links, nil
+}
+
+// decodeDevYaml decodes YAML, or JSON which is a subset of it, rejecting unknown fields.
+func decodeDevYaml(data []byte, v any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func plaintextSecrets(secrets map[string]string) map[string]SecretValue {
+	if secrets == nil {
+		return nil
+	}
+	values := make(map[string]SecretValue, len(secrets))
+	for key, value := range secrets {
+		values[key] = NewSecretString(value)
+	}
+	return values
+}
+
+func mergeMaps(base map[string]string, overrides map[string]string) map[string]string {
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string, len(overrides))
+	}
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// keyValueFlag is a repeatable key=value flag.
+type keyValueFlag map[string]string
+
+func (f *keyValueFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*f))
+	for key, value := range *f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *keyValueFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	if *f == nil {
+		*f = make(map[string]string)
+	}
+	(*f)[key] = val
+	return nil
+}
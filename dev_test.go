@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+func TestParseDevConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "dev.yaml")
+	err := os.WriteFile(configFile, []byte(`
+host_id: file-host
+provider_key: file-provider
+lattice: file-lattice
+log_level: debug
+config:
+  endpoint: http://localhost:8080
+  bucket: file
+secrets:
+  password: hunter2
+links:
+  - target: component
+    wit_namespace: wasi
+    wit_package: keyvalue
+    interfaces: [store]
+    source_secrets:
+      token: abc
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("WASMCLOUD_DEV_CONFIG", configFile)
+	t.Setenv("WASMCLOUD_LATTICE", "env-lattice")
+	t.Setenv("WASMCLOUD_PROVIDER_KEY", "env-provider")
+
+	// The provider can define its own flags
+	fs := flag.NewFlagSet("provider", flag.ContinueOnError)
+	verbose := fs.Bool("verbose", false, "")
+	config, err := ParseDevConfig(fs, []string{"-provider-key", "flag-provider", "-verbose", "-config", "bucket=flag", "extra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose || !slices.Equal(fs.Args(), []string{"extra"}) {
+		t.Errorf("expected the provider flags and remaining args, got %v and %v", *verbose, fs.Args())
+	}
+	if config.HostID != "file-host" || config.LatticeRPCPrefix != "env-lattice" || config.ProviderKey != "flag-provider" {
+		t.Errorf("unexpected precedence: %+v", config)
+	}
+	if config.Config["endpoint"] != "http://localhost:8080" || config.Config["bucket"] != "flag" {
+		t.Errorf("unexpected config: %v", config.Config)
+	}
+
+	hostData := config.HostData()
+	if hostData.LatticeRPCURL != "nats://127.0.0.1:4222" {
+		t.Errorf("expected local NATS URL, got %q", hostData.LatticeRPCURL)
+	}
+	if hostData.LogLevel == nil || *hostData.LogLevel != Debug {
+		t.Errorf("expected debug log level, got %v", hostData.LogLevel)
+	}
+	if secret, ok := hostData.Secrets["password"]; !ok || secret.String.Reveal() != "hunter2" {
+		t.Errorf("unexpected secrets: %v", hostData.Secrets)
+	}
+
+	link, err := config.Links[0].definition(hostData.ProviderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.SourceID != "flag-provider" || link.Name != "default" || link.SourceSecrets["token"].String.Reveal() != "abc" {
+		t.Errorf("unexpected link: %+v", link)
+	}
+
+	if _, err := (DevLink{Name: "self"}).definition(hostData.ProviderKey); err == nil {
+		t.Error("expected an error for a link without source_id and target")
+	}
+
+	if _, err := ParseDevConfig(nil, []string{"-config", "novalue"}); err == nil {
+		t.Error("expected an error for a malformed -config flag")
+	}
+
+	if err := os.WriteFile(configFile, []byte("unknown_field: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseDevConfig(nil, nil); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestNewDevLinksFile(t *testing.T) {
+	linksFile := filepath.Join(t.TempDir(), "links.yaml")
+	writeLinks := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(linksFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLinks(`{"links": [{"source_id": "component-a", "wit_namespace": "wasi", "wit_package": "keyvalue"}]}`)
+
+	puts := make(chan InterfaceLinkDefinition, 10)
+	dels := make(chan InterfaceLinkDefinition, 10)
+	wp, err := NewDev(DevConfig{
+		LatticeRPCURL:       startNatsServer(t, &server.Options{}),
+		LinksFile:           linksFile,
+		LinksReloadInterval: 10 * time.Millisecond,
+	},
+		TargetLinkPut(func(l InterfaceLinkDefinition) error { puts <- l; return nil }),
+		TargetLinkDel(func(l InterfaceLinkDefinition) error { dels <- l; return nil }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The links file isn't reloaded before the provider runs
+	writeLinks(`{"links": [{"source_id": "component-c", "wit_namespace": "wasi", "wit_package": "keyvalue"}]}`)
+	time.Sleep(50 * time.Millisecond)
+	if len(puts) != 0 || len(dels) != 0 {
+		t.Fatalf("expected no link changes before the provider runs, got %d puts and %d deletes", len(puts), len(dels))
+	}
+	writeLinks(`{"links": [{"source_id": "component-a", "wit_namespace": "wasi", "wit_package": "keyvalue"}]}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.StartContext(ctx)
+		done <- runResult{reason, err}
+	}()
+	defer func() {
+		cancel()
+		waitForExit(t, done)
+	}()
+
+	waitForLink := func(ch <-chan InterfaceLinkDefinition, sourceID string) {
+		t.Helper()
+		select {
+		case l := <-ch:
+			if l.SourceID != sourceID || l.Target != "dev-provider" {
+				t.Errorf("unexpected link: %+v", l)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for link from %s", sourceID)
+		}
+	}
+
+	waitForLink(puts, "component-a")
+
+	writeLinks(`
+links:
+  - source_id: component-b
+    wit_namespace: wasi
+    wit_package: keyvalue
+`)
+	waitForLink(puts, "component-b")
+	waitForLink(dels, "component-a")
+
+	if links := wp.TargetLinks(); len(links) != 1 || links[0].SourceID != "component-b" {
+		t.Errorf("unexpected links after reload: %+v", links)
+	}
+}
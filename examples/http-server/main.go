@@ -3,51 +3,16 @@
 package main
 
 import (
-	"io"
+	"context"
+	"errors"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"go.wasmcloud.dev/provider"
 	"go.wasmcloud.dev/provider/wrpchttp"
 )
 
-type Server struct {
-	wasiIncomingHandler *http.Client
-}
-
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// NOTE(lxf): net/http doesn't allow 'RequestURI' to be present in outbound requests, so we clear it here.
-	// https://go.dev/src/net/http/client.go
-	r.RequestURI = ""
-
-	resp, err := s.wasiIncomingHandler.Do(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	for k, vals := range resp.Header {
-		for _, v := range vals {
-			w.Header().Add(k, v)
-		}
-	}
-
-	w.WriteHeader(resp.StatusCode)
-
-	io.Copy(w, resp.Body)
-
-	for k, vals := range resp.Trailer {
-		for _, v := range vals {
-			w.Header().Add(k, v)
-		}
-	}
-}
-
 func main() {
 	// NOTE(lxf): Enable wrpc debugging
 	// lvl := new(slog.LevelVar)
@@ -66,48 +31,41 @@ func serveLocal(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello from the provider!"))
 }
 
+// withTimeout cancels the requests still being handled by h after timeout.
+func withTimeout(h http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func run() error {
 	wasmcloudprovider, err := provider.New()
 	if err != nil {
 		return err
 	}
 
-	providerCh := make(chan error, 1)
 	httpCh := make(chan error, 1)
-	signalCh := make(chan os.Signal, 1)
 
-	proxyServer := &Server{
-		wasiIncomingHandler: &http.Client{
-			Transport: wrpchttp.NewIncomingRoundTripper(wasmcloudprovider, wrpchttp.WithSingleTarget("http-http_component")),
-			Timeout:   time.Second * 5,
-		},
-	}
-
-	// Handle control interface operations
-	go func() {
-		err := wasmcloudprovider.Start()
-		providerCh <- err
-	}()
+	proxyServer := wrpchttp.NewReverseProxy(
+		wrpchttp.NewIncomingRoundTripper(wasmcloudprovider, wrpchttp.WithSingleTarget("http-http_component")),
+	)
 
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		mux := http.NewServeMux()
-		mux.Handle("/proxy", proxyServer)
+		mux.Handle("/proxy", withTimeout(proxyServer, 5*time.Second))
 		mux.Handle("/", http.HandlerFunc(serveLocal))
-		err := http.ListenAndServe(":8080", mux)
-		httpCh <- err
+		httpCh <- http.ListenAndServe(":8080", mux)
+		// Stop the provider if the HTTP server stops
+		cancel()
 	}()
 
-	// Shutdown on SIGINT
-	signal.Notify(signalCh, syscall.SIGINT)
-
-	select {
-	case err = <-providerCh:
-		return err
-	case <-httpCh:
-		wasmcloudprovider.Shutdown()
-	case <-signalCh:
-		wasmcloudprovider.Shutdown()
+	// Handle control interface operations until shutdown
+	reason, err := wasmcloudprovider.Run(ctx)
+	if reason == provider.ExitReasonContextDone {
+		return errors.Join(<-httpCh, err)
 	}
-
-	return nil
+	return err
 }
@@ -4,15 +4,14 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log"
 	"os"
-	"os/signal"
-	"syscall"
 
 	server "github.com/wasmCloud/provider-sdk-go/examples/keyvalue-inmemory/bindings"
-	"go.opentelemetry.io/otel"
 	"go.wasmcloud.dev/provider"
+	wrpc "wrpc.io/go"
 )
 
 func main() {
@@ -25,7 +24,6 @@ func run(source io.Reader) error {
 	p := &Provider{
 		sourceLinks: make(map[string]provider.InterfaceLinkDefinition),
 		targetLinks: make(map[string]provider.InterfaceLinkDefinition),
-		tracer:      otel.Tracer("keyvalue-inmemory"),
 	}
 
 	wasmcloudprovider, err := provider.NewWithHostDataSource(
@@ -41,35 +39,11 @@ func run(source io.Reader) error {
 		return err
 	}
 
-	providerCh := make(chan error, 1)
-	signalCh := make(chan os.Signal, 1)
-
-	// Handle RPC operations
-	stopFunc, err := server.Serve(wasmcloudprovider.RPCClient, p)
-	if err != nil {
-		wasmcloudprovider.Shutdown()
-		return err
-	}
-
-	// Handle control interface operations
-	go func() {
-		err := wasmcloudprovider.Start()
-		providerCh <- err
-	}()
-
-	// Shutdown on SIGINT
-	signal.Notify(signalCh, syscall.SIGINT)
-
-	select {
-	case err = <-providerCh:
-		stopFunc()
-		return err
-	case <-signalCh:
-		wasmcloudprovider.Shutdown()
-		stopFunc()
-	}
-
-	return nil
+	// Serve the exports and handle control interface operations until shutdown
+	_, err = wasmcloudprovider.Run(context.Background(), func(s wrpc.Server) (func() error, error) {
+		return server.Serve(s, p)
+	})
+	return err
 }
 
 func (p *Provider) handleNewSourceLink(link provider.InterfaceLinkDefinition) error {
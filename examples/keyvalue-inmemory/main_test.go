@@ -2,8 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -12,13 +10,10 @@ import (
 	"testing"
 	"time"
 
-	"github.com/nats-io/nats.go"
 	"github.com/wasmCloud/provider-sdk-go/examples/keyvalue-inmemory/bindings/testing/wrpc/keyvalue/store"
 	"go.wasmcloud.dev/provider"
+	"go.wasmcloud.dev/provider/providertest"
 	wrpcnats "wrpc.io/go/nats"
-
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 )
 
 func TestSet(t *testing.T) {
@@ -319,116 +314,27 @@ func TestListKeys(t *testing.T) {
 }
 
 func NewTestEnvironment(ctx context.Context, t testing.TB) (*TestEnvironment, error) {
-	natsServer, err := startContainer(ctx)
 	normalizedName := strings.Replace(t.Name(), "/", "-", -1)
-	testcontainers.CleanupContainer(t, natsServer.Container)
-	if err != nil {
-		return nil, err
-	}
-	hostData := provider.HostData{
+	host := providertest.NewHost(t, provider.HostData{
 		HostID:           "test-host",
 		LatticeRPCPrefix: normalizedName,
-		LatticeRPCURL:    natsServer.URI,
 		ProviderKey:      normalizedName,
-		// EnvValues:              map[string]string{},
-		// InstanceID:             "",
-		// ClusterIssuers:         []string{},
-		// Config:                 map[string]string{},
-		// Secrets:                map[string]provider.SecretValue{},
-		// HostXKeyPublicKey: "",
-		// ProviderXKeyPrivateKey: "",
-		// HostXKeyPublicKey:      "",
-		// LogLevel:               &"",
-		// OtelConfig:             provider.OtelConfig{},
-	}
-	return &TestEnvironment{
-		hostData: hostData,
-		nats:     natsServer,
-	}, nil
+	})
+	return &TestEnvironment{host: host}, nil
 }
 
 type TestEnvironment struct {
-	hostData provider.HostData
-	nats     *natsServer
+	host *providertest.Host
 }
 
 func (te *TestEnvironment) EnsureProviderStarted() error {
-	nc, ncErr := nats.Connect(te.hostData.LatticeRPCURL)
-	if ncErr != nil {
-		return fmt.Errorf("failed to connect to NATS: %v", ncErr)
-	}
-	for range 50 {
-		time.Sleep(100 * time.Millisecond)
-		_, err := nc.Request(te.ProviderHealthEndpoint(), nil, 100*time.Millisecond)
-		if err == nil {
-			break
-		} else if err == nats.ErrNoResponders {
-			continue
-		} else {
-			return err
-		}
-	}
-	return nil
+	return te.host.WaitForProvider(5 * time.Second)
 }
 
 func (te *TestEnvironment) HostDataSource() (io.Reader, error) {
-	hostDataJson, err := json.Marshal(te.hostData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal HostData to json")
-	}
-	encoded := strings.NewReader(base64.StdEncoding.EncodeToString(hostDataJson))
-	return encoded, nil
-}
-
-func (te *TestEnvironment) ProviderHealthEndpoint() string {
-	return fmt.Sprintf("wasmbus.rpc.%s.health", te.ProviderNamespace())
-}
-
-func (te *TestEnvironment) ProviderNamespace() string {
-	return fmt.Sprintf("%s.%s", te.hostData.LatticeRPCPrefix, te.hostData.ProviderKey)
+	return te.host.HostDataSource(), nil
 }
 
 func (te *TestEnvironment) WrpcClient() (*wrpcnats.Client, error) {
-	nc, err := nats.Connect(te.hostData.LatticeRPCURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
-	}
-	return wrpcnats.NewClient(nc, wrpcnats.WithPrefix(te.ProviderNamespace())), nil
-}
-
-type natsServer struct {
-	testcontainers.Container
-	URI string
-}
-
-func startContainer(ctx context.Context) (*natsServer, error) {
-	req := testcontainers.ContainerRequest{
-		Image:        "library/nats",
-		ExposedPorts: []string{"4222/tcp"},
-		WaitingFor:   wait.ForLog("Server is ready").WithStartupTimeout(10 * time.Second),
-	}
-	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: req,
-		Started:          true,
-	})
-	var nats *natsServer
-	if container != nil {
-		nats = &natsServer{Container: container}
-	}
-	if err != nil {
-		return nats, err
-	}
-
-	natsIp, err := container.Host(ctx)
-	if err != nil {
-		return nats, err
-	}
-
-	natsPort, err := container.MappedPort(ctx, "4222")
-	if err != nil {
-		return nats, err
-	}
-
-	nats.URI = fmt.Sprintf("nats://%s:%s", natsIp, natsPort.Port())
-	return nats, nil
+	return te.host.WrpcClient(), nil
 }
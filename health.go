@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	defaultHealthCheckTimeout = 5 * time.Second
+)
+
+// HealthStatus is the result of a single health check.
+type HealthStatus struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthCheckFunc checks the health of (part of) a provider. Returning an error marks
+// the check as unhealthy, with the error as its message.
+type HealthCheckFunc func(ctx context.Context) (HealthStatus, error)
+
+type namedHealthCheck struct {
+	name    string
+	timeout time.Duration
+	check   HealthCheckFunc
+}
+
+// healthCheck builds the response to a health check request. The provider is reported
+// as unhealthy whenever the lattice connection isn't established.
+func (wp *WasmcloudProvider) healthCheck(ctx context.Context) (resp HealthCheckResponse) {
+	start := time.Now()
+	defer func() {
+		wp.metrics.recordHealthCheck(ctx, start, resp.Healthy)
+	}()
+
+	if state := wp.ConnectionState(); state != nats.CONNECTED {
+		return HealthCheckResponse{
+			Healthy: false,
+			Message: fmt.Sprintf("lattice connection is %s", strings.ToLower(state.String())),
+		}
+	}
+
+	return wp.runHealthChecks(ctx)
+}
+
+// runHealthChecks runs the provider health check and all named health checks concurrently,
+// each with its own timeout, and aggregates their results. The provider is healthy only if
+// every check is healthy.
+func (wp *WasmcloudProvider) runHealthChecks(ctx context.Context) HealthCheckResponse {
+	var wg sync.WaitGroup
+	var status HealthStatus
+	statuses := make([]HealthStatus, len(wp.healthChecks))
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		status = runHealthCheck(ctx, wp.healthCheckTimeout, wp.healthCheckFunc)
+	}()
+	for i, check := range wp.healthChecks {
+		timeout := check.timeout
+		if timeout <= 0 {
+			timeout = wp.healthCheckTimeout
+		}
+		wg.Add(1)
+		go func(i int, check HealthCheckFunc) {
+			defer wg.Done()
+			statuses[i] = runHealthCheck(ctx, timeout, check)
+		}(i, check.check)
+	}
+	wg.Wait()
+
+	resp := HealthCheckResponse{
+		Healthy: status.Healthy,
+		Message: status.Message,
+	}
+	if len(wp.healthChecks) == 0 {
+		return resp
+	}
+
+	var failures []string
+	if !status.Healthy {
+		failures = append(failures, status.Message)
+	}
+	resp.Checks = make(map[string]HealthStatus, len(wp.healthChecks))
+	for i, check := range wp.healthChecks {
+		resp.Checks[check.name] = statuses[i]
+		if !statuses[i].Healthy {
+			resp.Healthy = false
+			failures = append(failures, fmt.Sprintf("%s: %s", check.name, statuses[i].Message))
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		resp.Message = strings.Join(failures, "; ")
+	}
+
+	return resp
+}
+
+// runHealthCheck runs a single check, reporting it as unhealthy if it returns an error,
+// panics or doesn't complete within the timeout.
+func runHealthCheck(ctx context.Context, timeout time.Duration, check HealthCheckFunc) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := make(chan HealthStatus, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- HealthStatus{Healthy: false, Message: fmt.Sprintf("health check panicked: %v", r)}
+			}
+		}()
+
+		status, err := check(ctx)
+		if err != nil {
+			status = HealthStatus{Healthy: false, Message: err.Error()}
+		}
+		result <- status
+	}()
+
+	select {
+	case status := <-result:
+		return status
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return HealthStatus{Healthy: false, Message: fmt.Sprintf("health check timed out after %s", timeout)}
+		}
+		return HealthStatus{Healthy: false, Message: ctx.Err().Error()}
+	}
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthChecks(t *testing.T) {
+	healthy := func(msg string) HealthCheckFunc {
+		return func(context.Context) (HealthStatus, error) {
+			return HealthStatus{Healthy: true, Message: msg}, nil
+		}
+	}
+	unhealthy := func(msg string) HealthCheckFunc {
+		return func(context.Context) (HealthStatus, error) {
+			return HealthStatus{Healthy: false, Message: msg}, nil
+		}
+	}
+	failing := func(msg string) HealthCheckFunc {
+		return func(context.Context) (HealthStatus, error) {
+			return HealthStatus{}, errors.New(msg)
+		}
+	}
+	hanging := func(ctx context.Context) (HealthStatus, error) {
+		<-ctx.Done()
+		return HealthStatus{Healthy: true}, nil
+	}
+	ignoringContext := func(context.Context) (HealthStatus, error) {
+		time.Sleep(time.Second)
+		return HealthStatus{Healthy: true}, nil
+	}
+	panicking := func(context.Context) (HealthStatus, error) {
+		panic("boom")
+	}
+
+	type test struct {
+		name     string
+		options  []ProviderHandler
+		healthy  bool
+		message  string
+		checks   map[string]bool
+		contains []string
+	}
+
+	tests := []test{
+		{
+			name:    "Default",
+			healthy: true,
+			message: "healthy",
+		},
+		{
+			name:    "Legacy string health check",
+			options: []ProviderHandler{HealthCheck(func() string { return "all good" })},
+			healthy: true,
+			message: "all good",
+		},
+		{
+			name:    "Unhealthy status",
+			options: []ProviderHandler{HealthCheckStatus(unhealthy("database unreachable"))},
+			healthy: false,
+			message: "database unreachable",
+		},
+		{
+			name:    "Error",
+			options: []ProviderHandler{HealthCheckStatus(failing("connection refused"))},
+			healthy: false,
+			message: "connection refused",
+		},
+		{
+			name:     "Timeout",
+			options:  []ProviderHandler{HealthCheckTimeout(10 * time.Millisecond), HealthCheckStatus(hanging)},
+			healthy:  false,
+			contains: []string{"timed out after 10ms"},
+		},
+		{
+			name:     "Check ignoring the context",
+			options:  []ProviderHandler{HealthCheckTimeout(10 * time.Millisecond), HealthCheckStatus(ignoringContext)},
+			healthy:  false,
+			contains: []string{"timed out after 10ms"},
+		},
+		{
+			name:     "Panic",
+			options:  []ProviderHandler{HealthCheckStatus(panicking)},
+			healthy:  false,
+			contains: []string{"panicked: boom"},
+		},
+		{
+			name: "Healthy named checks",
+			options: []ProviderHandler{
+				HealthCheck(func() string { return "all good" }),
+				NamedHealthCheck("database", 0, healthy("")),
+				NamedHealthCheck("cache", 0, healthy("")),
+			},
+			healthy: true,
+			message: "all good",
+			checks:  map[string]bool{"database": true, "cache": true},
+		},
+		{
+			name: "Aggregated named checks",
+			options: []ProviderHandler{
+				NamedHealthCheck("database", 0, failing("connection refused")),
+				NamedHealthCheck("cache", 10*time.Millisecond, hanging),
+				NamedHealthCheck("queue", 0, healthy("")),
+			},
+			healthy:  false,
+			checks:   map[string]bool{"database": false, "cache": false, "queue": true},
+			contains: []string{"database: connection refused", "cache: health check timed out after 10ms"},
+		},
+	}
+
+	for _, tc := range tests {
+		wp := &WasmcloudProvider{
+			healthCheckFunc:    healthy("healthy"),
+			healthCheckTimeout: defaultHealthCheckTimeout,
+		}
+		for _, opt := range tc.options {
+			if err := opt(wp); err != nil {
+				t.Fatalf("%s: expected err to be nil, got: %v", tc.name, err)
+			}
+		}
+
+		resp := wp.runHealthChecks(context.Background())
+		if resp.Healthy != tc.healthy {
+			t.Errorf("%s: expected healthy to be %t, got: %+v", tc.name, tc.healthy, resp)
+		}
+		if tc.message != "" && resp.Message != tc.message {
+			t.Errorf("%s: expected message %q, got %q", tc.name, tc.message, resp.Message)
+		}
+		for _, s := range tc.contains {
+			if !strings.Contains(resp.Message, s) {
+				t.Errorf("%s: expected message to contain %q, got %q", tc.name, s, resp.Message)
+			}
+		}
+		if len(resp.Checks) != len(tc.checks) {
+			t.Errorf("%s: expected %d checks, got %d", tc.name, len(tc.checks), len(resp.Checks))
+		}
+		for name, healthy := range tc.checks {
+			if resp.Checks[name].Healthy != healthy {
+				t.Errorf("%s: expected check %q healthy to be %t, got: %+v", tc.name, name, healthy, resp.Checks[name])
+			}
+		}
+	}
+}
+
+func TestNamedHealthCheckDuplicate(t *testing.T) {
+	wp := &WasmcloudProvider{}
+	check := func(context.Context) (HealthStatus, error) { return HealthStatus{Healthy: true}, nil }
+
+	if err := NamedHealthCheck("database", 0, check)(wp); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	if err := NamedHealthCheck("database", 0, check)(wp); err == nil {
+		t.Error("Expected registering a duplicate health check to fail")
+	}
+}
@@ -33,6 +33,43 @@ type OtelConfig struct {
 	MetricsEndpoint       string `json:"metrics_endpoint,omitempty"`
 	LogsEndpoint          string `json:"logs_endpoint,omitempty"`
 	Protocol              string `json:"protocol,omitempty"`
+
+	// TracesSampler is one of the OTEL_TRACES_SAMPLER samplers (ex: "parentbased_traceidratio"),
+	// always_on by default. TracesSamplerArg is the ratio of the ratio based samplers.
+	TracesSampler    string `json:"traces_sampler,omitempty"`
+	TracesSamplerArg string `json:"traces_sampler_arg,omitempty"`
+	// Export intervals in milliseconds, see OtelTraceExportInterval, OtelMetricExportInterval
+	// and OtelLogExportInterval for the defaults.
+	TracesExportIntervalMS  *uint64 `json:"traces_export_interval_ms,omitempty"`
+	MetricsExportIntervalMS *uint64 `json:"metrics_export_interval_ms,omitempty"`
+	LogsExportIntervalMS    *uint64 `json:"logs_export_interval_ms,omitempty"`
+	// MaxBatchQueueSize and MaxExportBatchSize tune the batching of spans and logs.
+	MaxBatchQueueSize  int            `json:"max_batch_queue_size,omitempty"`
+	MaxExportBatchSize int            `json:"max_export_batch_size,omitempty"`
+	SpanLimits         OtelSpanLimits `json:"span_limits,omitempty"`
+
+	// Headers are sent with every export, ex: for authentication. Their values are redacted
+	// when logged.
+	Headers map[string]RedactedString `json:"headers,omitempty"`
+	// Compression is either "gzip" or "none".
+	Compression string `json:"compression,omitempty"`
+	// AdditionalCAPaths are PEM files of certificate authorities trusted by the exporters, on
+	// top of the system ones.
+	AdditionalCAPaths []string `json:"additional_ca_paths,omitempty"`
+	// ClientCertificatePath and ClientKeyPath are PEM files used for mutual TLS.
+	ClientCertificatePath string `json:"client_certificate_path,omitempty"`
+	ClientKeyPath         string `json:"client_key_path,omitempty"`
+}
+
+// OtelSpanLimits limits the content of spans. Zero values are unset and use the OTEL_SPAN_*
+// environment variables or the defaults, negative values mean unlimited.
+type OtelSpanLimits struct {
+	AttributeCount         int `json:"attribute_count,omitempty"`
+	AttributeValueLength   int `json:"attribute_value_length,omitempty"`
+	EventCount             int `json:"event_count,omitempty"`
+	LinkCount              int `json:"link_count,omitempty"`
+	AttributePerEventCount int `json:"attribute_per_event_count,omitempty"`
+	AttributePerLinkCount  int `json:"attribute_per_link_count,omitempty"`
 }
 
 type otelSignal int
@@ -151,7 +188,7 @@ type HostData struct {
 	HostID                 string                     `json:"host_id,omitempty"`
 	LatticeRPCPrefix       string                     `json:"lattice_rpc_prefix,omitempty"`
 	LatticeRPCUserJWT      string                     `json:"lattice_rpc_user_jwt,omitempty"`
-	LatticeRPCUserSeed     string                     `json:"lattice_rpc_user_seed,omitempty"`
+	LatticeRPCUserSeed     RedactedString             `json:"lattice_rpc_user_seed,omitempty"`
 	LatticeRPCURL          string                     `json:"lattice_rpc_url,omitempty"`
 	ProviderKey            string                     `json:"provider_key,omitempty"`
 	EnvValues              map[string]string          `json:"env_values,omitempty"`
@@ -171,4 +208,6 @@ type HostData struct {
 type HealthCheckResponse struct {
 	Healthy bool   `json:"healthy"`
 	Message string `json:"message,omitempty"`
+	// Checks holds the result of each named health check, if any are registered.
+	Checks map[string]HealthStatus `json:"checks,omitempty"`
 }
@@ -29,6 +29,19 @@ func TestRedactedStringLogging(t *testing.T) {
 	}
 }
 
+func TestOtelConfigHeadersLogging(t *testing.T) {
+	var buf bytes.Buffer
+	secret := "Bearer its-a-secret"
+	hostData := HostData{OtelConfig: OtelConfig{Headers: map[string]RedactedString{"authorization": RedactedString(secret)}}}
+
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("jsonSlog", "config", hostData)
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("textSlog", "config", hostData)
+
+	if strings.Contains(buf.String(), secret) {
+		t.Errorf("slog output should not have contained the otel headers, got %s", buf.String())
+	}
+}
+
 func TestOtelConfigProtocol(t *testing.T) {
 	type test struct {
 		name     string
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultHostDataTimeout is how long New and NewWithHostDataSource wait for the host data.
+const DefaultHostDataTimeout = 5 * time.Second
+
+var (
+	// ErrHostDataTimeout is returned when the host data isn't received in time.
+	ErrHostDataTimeout = errors.New("timed out reading host data")
+	// ErrHostDataDecode is returned when the host data can't be read or decoded.
+	ErrHostDataDecode = errors.New("failed to decode host data")
+)
+
+// ReadHostData reads the base64 encoded host data line the host writes to the provider's
+// stdin, waiting at most timeout for it. A zero timeout waits forever.
+//
+// Readers supporting read deadlines (ex: os.Stdin when it's a pipe) are read directly.
+// Others are read from a goroutine, and closed on timeout if they implement io.Closer so that
+// the read is interrupted (ex: io.PipeReader or net.Conn). Reads of other readers can't be
+// interrupted (ex: a plain io.Reader, or os.Stdin when it's a terminal), so on timeout their
+// goroutine stays blocked until the read returns.
+func ReadHostData(source io.Reader, timeout time.Duration) (HostData, error) {
+	raw, err := readHostDataLine(source, timeout)
+	if err != nil {
+		return HostData{}, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(raw)))
+	if err != nil {
+		return HostData{}, fmt.Errorf("%w: %w", ErrHostDataDecode, err)
+	}
+	return decodeHostData(decoded)
+}
+
+// ParseHostData decodes host data given either as JSON or as base64 encoded JSON, the
+// way the host sends it.
+func ParseHostData(data []byte) (HostData, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return HostData{}, fmt.Errorf("%w: %w", ErrHostDataDecode, err)
+		}
+		data = decoded
+	}
+	return decodeHostData(data)
+}
+
+// HostDataFromFile reads host data from a JSON or base64 encoded file, useful to run a
+// provider outside of a host.
+func HostDataFromFile(path string) (HostData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HostData{}, fmt.Errorf("%w: %w", ErrHostDataDecode, err)
+	}
+	return ParseHostData(data)
+}
+
+// HostDataFromEnv reads host data from a JSON or base64 encoded environment variable,
+// useful to run a provider outside of a host.
+func HostDataFromEnv(name string) (HostData, error) {
+	data, ok := os.LookupEnv(name)
+	if !ok {
+		return HostData{}, fmt.Errorf("%w: environment variable %s isn't set", ErrHostDataDecode, name)
+	}
+	return ParseHostData([]byte(data))
+}
+
+// MarshalHostData returns hostData as the JSON sent by the host. Unlike json.Marshal, the
+// real values of the redacted fields and secrets are kept, so that the result can be read
+// by a provider, ex: in tests or tools running providers without a host.
+func MarshalHostData(hostData HostData) ([]byte, error) {
+	data, err := json.Marshal(hostData)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if hostData.LatticeRPCUserSeed != "" {
+		raw["lattice_rpc_user_seed"] = hostData.LatticeRPCUserSeed.Reveal()
+	}
+	if hostData.ProviderXKeyPrivateKey != "" {
+		raw["provider_xkey_private_key"] = hostData.ProviderXKeyPrivateKey.Reveal()
+	}
+	if len(hostData.Secrets) > 0 {
+		raw["secrets"] = revealSecrets(hostData.Secrets)
+	}
+	if otelConfig, ok := raw["otel_config"].(map[string]any); ok && len(hostData.OtelConfig.Headers) > 0 {
+		headers := make(map[string]string, len(hostData.OtelConfig.Headers))
+		for key, value := range hostData.OtelConfig.Headers {
+			headers[key] = value.Reveal()
+		}
+		otelConfig["headers"] = headers
+	}
+	return json.Marshal(raw)
+}
+
+// EncodeHostData returns hostData as the base64 encoded line the host writes to the
+// provider's stdin, see MarshalHostData and NewWithHostDataSource.
+func EncodeHostData(hostData HostData) (string, error) {
+	data, err := MarshalHostData(hostData)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data) + "\n", nil
+}
+
+func decodeHostData(data []byte) (HostData, error) {
+	var hostData HostData
+	if err := json.Unmarshal(data, &hostData); err != nil {
+		return HostData{}, fmt.Errorf("%w: %w", ErrHostDataDecode, err)
+	}
+	return hostData, nil
+}
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+func readHostDataLine(source io.Reader, timeout time.Duration) ([]byte, error) {
+	reader := bufio.NewReader(source)
+	if timeout <= 0 {
+		return checkHostDataRead(reader.ReadBytes('\n'))
+	}
+
+	// Prefer read deadlines so no goroutine is left blocked on the reader after a timeout
+	if d, ok := source.(readDeadliner); ok && d.SetReadDeadline(time.Now().Add(timeout)) == nil {
+		defer func() { _ = d.SetReadDeadline(time.Time{}) }()
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("%w: nothing received after %s", ErrHostDataTimeout, timeout)
+		}
+		return checkHostDataRead(line, err)
+	}
+
+	type result struct {
+		line []byte
+		err  error
+	}
+	// Buffered so the reading goroutine never blocks once the read returns
+	resultCh := make(chan result, 1)
+	go func() {
+		line, err := reader.ReadBytes('\n')
+		resultCh <- result{line, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-resultCh:
+		return checkHostDataRead(r.line, r.err)
+	case <-timer.C:
+		// Interrupt the read, if possible, so the goroutine exits
+		if closer, ok := source.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, fmt.Errorf("%w: nothing received after %s", ErrHostDataTimeout, timeout)
+	}
+}
+
+func checkHostDataRead(line []byte, err error) ([]byte, error) {
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %w", ErrHostDataDecode, err)
+	}
+	return line, nil
+}
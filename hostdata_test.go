@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHostDataJson = `{"host_id":"test-host","lattice_rpc_prefix":"default","provider_key":"test-provider"}`
+
+func TestReadHostData(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(testHostDataJson))
+
+	hostData, err := ReadHostData(strings.NewReader(encoded+"\n"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostData.HostID != "test-host" || hostData.ProviderKey != "test-provider" {
+		t.Errorf("unexpected host data: %+v", hostData)
+	}
+
+	// The host data doesn't have to end with a newline
+	if _, err := ReadHostData(strings.NewReader(encoded), time.Second); err != nil {
+		t.Error(err)
+	}
+
+	for name, input := range map[string]string{
+		"empty":      "",
+		"not base64": "not-base64!\n",
+		"not json":   base64.StdEncoding.EncodeToString([]byte("nope")) + "\n",
+	} {
+		if _, err := ReadHostData(strings.NewReader(input), time.Second); !errors.Is(err, ErrHostDataDecode) {
+			t.Errorf("%s: expected ErrHostDataDecode, got %v", name, err)
+		}
+	}
+}
+
+func TestReadHostDataTimeout(t *testing.T) {
+	t.Run("deadline", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		defer w.Close()
+
+		if _, err := ReadHostData(r, 50*time.Millisecond); !errors.Is(err, ErrHostDataTimeout) {
+			t.Fatalf("expected ErrHostDataTimeout, got %v", err)
+		}
+	})
+
+	t.Run("goroutine", func(t *testing.T) {
+		r, w := io.Pipe()
+		defer w.Close()
+
+		before := runtime.NumGoroutine()
+		if _, err := ReadHostData(r, 50*time.Millisecond); !errors.Is(err, ErrHostDataTimeout) {
+			t.Fatalf("expected ErrHostDataTimeout, got %v", err)
+		}
+		// The reader is closed so the goroutine reading it exits
+		for i := 0; runtime.NumGoroutine() > before; i++ {
+			if i == 100 {
+				t.Fatalf("expected the reading goroutine to exit, got %d goroutines, had %d", runtime.NumGoroutine(), before)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if _, err := w.Write([]byte("late\n")); !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected the reader to be closed, got %v", err)
+		}
+	})
+}
+
+func TestParseHostData(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(testHostDataJson))
+	for _, input := range []string{testHostDataJson, encoded, "  " + testHostDataJson + "\n"} {
+		hostData, err := ParseHostData([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hostData.HostID != "test-host" {
+			t.Errorf("unexpected host data: %+v", hostData)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "host-data.json")
+	if err := os.WriteFile(path, []byte(testHostDataJson), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if hostData, err := HostDataFromFile(path); err != nil || hostData.HostID != "test-host" {
+		t.Errorf("unexpected host data from file: %+v, %v", hostData, err)
+	}
+	if _, err := HostDataFromFile(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, ErrHostDataDecode) {
+		t.Errorf("expected ErrHostDataDecode, got %v", err)
+	}
+
+	t.Setenv("TEST_HOST_DATA", encoded)
+	if hostData, err := HostDataFromEnv("TEST_HOST_DATA"); err != nil || hostData.HostID != "test-host" {
+		t.Errorf("unexpected host data from env: %+v, %v", hostData, err)
+	}
+	if _, err := HostDataFromEnv("TEST_HOST_DATA_MISSING"); !errors.Is(err, ErrHostDataDecode) {
+		t.Errorf("expected ErrHostDataDecode, got %v", err)
+	}
+}
+
+func TestEncodeHostData(t *testing.T) {
+	hostData := HostData{
+		HostID:                 "test-host",
+		LatticeRPCUserSeed:     "user-seed",
+		ProviderXKeyPrivateKey: "xkey-seed",
+		Secrets: map[string]SecretValue{
+			"password": NewSecretString("hunter2"),
+			"key":      NewSecretBytes([]byte{1, 2, 3}),
+		},
+		OtelConfig: OtelConfig{Headers: map[string]RedactedString{"authorization": "Bearer token"}},
+	}
+
+	encoded, err := EncodeHostData(hostData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadHostData(strings.NewReader(encoded), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The redacted values are sent as is
+	if decoded.LatticeRPCUserSeed.Reveal() != "user-seed" || decoded.ProviderXKeyPrivateKey.Reveal() != "xkey-seed" {
+		t.Errorf("unexpected seeds: %q, %q", decoded.LatticeRPCUserSeed.Reveal(), decoded.ProviderXKeyPrivateKey.Reveal())
+	}
+	if decoded.Secrets["password"].String.Reveal() != "hunter2" || string(decoded.Secrets["key"].Bytes.Reveal()) != "\x01\x02\x03" {
+		t.Errorf("unexpected secrets: %v", decoded.Secrets)
+	}
+	if decoded.OtelConfig.Headers["authorization"].Reveal() != "Bearer token" {
+		t.Errorf("unexpected otel headers: %v", decoded.OtelConfig.Headers)
+	}
+}
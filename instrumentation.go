@@ -0,0 +1,316 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	wrpc "wrpc.io/go"
+	wrpcnats "wrpc.io/go/nats"
+)
+
+const instrumentationName = "go.wasmcloud.dev/provider"
+
+// InstrumentServer wraps a wRPC server so each invocation of a served function continues
+// the W3C trace context found in the NATS headers of the invocation, runs in a server span
+// and has its duration recorded in the rpc.server.duration histogram. The in-flight, total and
+// failed invocations of each interface are counted as well. The provider's RPCServer is
+// already instrumented.
+func InstrumentServer(server wrpc.Server) wrpc.Server {
+	return newInstrumentedServer(server, otel.GetTracerProvider(), otel.GetMeterProvider())
+}
+
+// InstrumentInvoker wraps a wRPC invoker so each invocation runs in a client span, whose
+// W3C trace context is sent in the NATS headers of the invocation, and has its duration
+// recorded in the rpc.client.duration histogram. The span ends once the reader returned by
+// Invoke and every reader indexed from it, ex: for a streamed response body, are closed, so
+// the duration covers the whole response. See OutgoingInvoker.
+func InstrumentInvoker(invoker wrpc.Invoker) wrpc.Invoker {
+	return newInstrumentedInvoker(invoker, otel.GetTracerProvider(), otel.GetMeterProvider())
+}
+
+type instrumentedServer struct {
+	server   wrpc.Server
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	active   metric.Int64UpDownCounter
+	total    metric.Int64Counter
+	failed   metric.Int64Counter
+}
+
+func newInstrumentedServer(server wrpc.Server, tp trace.TracerProvider, mp metric.MeterProvider) *instrumentedServer {
+	s := &instrumentedServer{
+		server:   server,
+		tracer:   tp.Tracer(instrumentationName),
+		duration: newRPCDurationHistogram(mp, "rpc.server.duration", "Duration of the wRPC invocations served"),
+	}
+
+	meter := mp.Meter(instrumentationName)
+	var errs []error
+	var err error
+	s.active, err = meter.Int64UpDownCounter("wasmcloud.provider.rpc.invocations.active",
+		metric.WithDescription("wRPC invocations being served"))
+	errs = append(errs, err)
+	s.total, err = meter.Int64Counter("wasmcloud.provider.rpc.invocations",
+		metric.WithDescription("wRPC invocations served"))
+	errs = append(errs, err)
+	s.failed, err = meter.Int64Counter("wasmcloud.provider.rpc.invocations.errors",
+		metric.WithDescription("wRPC invocations served that failed"))
+	errs = append(errs, err)
+	if err := errors.Join(errs...); err != nil {
+		otel.Handle(err)
+	}
+	return s
+}
+
+func (s *instrumentedServer) Serve(instance string, name string, f func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser), paths ...wrpc.SubscribePath) (func() error, error) {
+	attrs := rpcAttributes(instance, name)
+	// Counters are per interface, the function is only known to the spans and durations
+	counterAttrs := metric.WithAttributes(semconv.RPCServiceKey.String(instance))
+	return s.server.Serve(instance, name, func(ctx context.Context, w wrpc.IndexWriteCloser, r wrpc.IndexReadCloser) {
+		if header, ok := wrpcnats.HeaderFromContext(ctx); ok {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, natsHeaderCarrier(header))
+		}
+		start := time.Now()
+		ctx, span := s.tracer.Start(ctx, rpcSpanName(instance, name),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+
+		s.active.Add(ctx, 1, counterAttrs)
+		s.total.Add(ctx, 1, counterAttrs)
+		errs := &rpcErrors{}
+		defer func() {
+			err := errs.first()
+			if err != nil {
+				s.failed.Add(ctx, 1, counterAttrs)
+			}
+			s.active.Add(ctx, -1, counterAttrs)
+			endRPC(ctx, span, s.duration, start, attrs, err)
+		}()
+		f(ctx, &instrumentedWriter{IndexWriteCloser: w, errs: errs}, r)
+	}, paths...)
+}
+
+type instrumentedInvoker struct {
+	invoker  wrpc.Invoker
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+}
+
+func newInstrumentedInvoker(invoker wrpc.Invoker, tp trace.TracerProvider, mp metric.MeterProvider) *instrumentedInvoker {
+	return &instrumentedInvoker{
+		invoker:  invoker,
+		tracer:   tp.Tracer(instrumentationName),
+		duration: newRPCDurationHistogram(mp, "rpc.client.duration", "Duration of the outgoing wRPC invocations"),
+	}
+}
+
+func (i *instrumentedInvoker) Invoke(ctx context.Context, instance string, name string, params []byte, paths ...wrpc.SubscribePath) (wrpc.IndexWriteCloser, wrpc.IndexReadCloser, error) {
+	attrs := rpcAttributes(instance, name)
+	start := time.Now()
+	ctx, span := i.tracer.Start(ctx, rpcSpanName(instance, name),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	// Keep the headers already set by the caller, without modifying them
+	header := nats.Header{}
+	if existing, ok := wrpcnats.HeaderFromContext(ctx); ok {
+		for key, values := range existing {
+			header[key] = slices.Clone(values)
+		}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, natsHeaderCarrier(header))
+
+	w, r, err := i.invoker.Invoke(wrpcnats.ContextWithHeader(ctx, header), instance, name, params, paths...)
+	if err != nil {
+		endRPC(ctx, span, i.duration, start, attrs, err)
+		return nil, nil, err
+	}
+
+	errs := &rpcErrors{}
+	readers := &rpcReaders{end: sync.OnceFunc(func() {
+		endRPC(ctx, span, i.duration, start, attrs, errs.first())
+	})}
+	return w, newInstrumentedReader(r, errs, readers), nil
+}
+
+func newRPCDurationHistogram(mp metric.MeterProvider, name string, description string) metric.Float64Histogram {
+	histogram, err := mp.Meter(instrumentationName).Float64Histogram(name,
+		metric.WithUnit("ms"),
+		metric.WithDescription(description),
+	)
+	if err != nil {
+		otel.Handle(err)
+		return noop.Float64Histogram{}
+	}
+	return histogram
+}
+
+func rpcAttributes(instance string, name string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.RPCSystemKey.String("wrpc"),
+		semconv.RPCServiceKey.String(instance),
+		semconv.RPCMethodKey.String(name),
+	}
+}
+
+func rpcSpanName(instance string, name string) string {
+	return instance + "/" + name
+}
+
+// endRPC ends the span of an invocation and records its duration, marking both as failed
+// if err isn't nil.
+func endRPC(ctx context.Context, span trace.Span, duration metric.Float64Histogram, start time.Time, attrs []attribute.KeyValue, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(slices.Clip(attrs), attribute.String("error.type", fmt.Sprintf("%T", err)))
+	}
+	duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), metric.WithAttributes(attrs...))
+	span.End()
+}
+
+// rpcErrors keeps the first error seen while reading or writing an invocation, possibly
+// from multiple goroutines for async values.
+type rpcErrors struct {
+	lock sync.Mutex
+	err  error
+}
+
+func (e *rpcErrors) record(err error) {
+	if err == nil || errors.Is(err, io.EOF) {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *rpcErrors) first() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.err
+}
+
+// rpcReaders counts the open readers of an invocation, the span of the invocation ends
+// when the last one is closed.
+type rpcReaders struct {
+	open atomic.Int64
+	end  func()
+}
+
+func (r *rpcReaders) closed() {
+	if r.open.Add(-1) == 0 {
+		r.end()
+	}
+}
+
+type instrumentedWriter struct {
+	wrpc.IndexWriteCloser
+	errs *rpcErrors
+}
+
+func (w *instrumentedWriter) Write(p []byte) (int, error) {
+	n, err := w.IndexWriteCloser.Write(p)
+	w.errs.record(err)
+	return n, err
+}
+
+func (w *instrumentedWriter) WriteByte(b byte) error {
+	err := w.IndexWriteCloser.WriteByte(b)
+	w.errs.record(err)
+	return err
+}
+
+func (w *instrumentedWriter) Index(path ...uint32) (wrpc.IndexWriteCloser, error) {
+	inner, err := w.IndexWriteCloser.Index(path...)
+	w.errs.record(err)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedWriter{IndexWriteCloser: inner, errs: w.errs}, nil
+}
+
+func (w *instrumentedWriter) Close() error {
+	err := w.IndexWriteCloser.Close()
+	w.errs.record(err)
+	return err
+}
+
+type instrumentedReader struct {
+	wrpc.IndexReadCloser
+	errs    *rpcErrors
+	readers *rpcReaders
+	closed  atomic.Bool
+}
+
+func newInstrumentedReader(r wrpc.IndexReadCloser, errs *rpcErrors, readers *rpcReaders) *instrumentedReader {
+	readers.open.Add(1)
+	return &instrumentedReader{IndexReadCloser: r, errs: errs, readers: readers}
+}
+
+func (r *instrumentedReader) Read(p []byte) (int, error) {
+	n, err := r.IndexReadCloser.Read(p)
+	r.errs.record(err)
+	return n, err
+}
+
+func (r *instrumentedReader) ReadByte() (byte, error) {
+	b, err := r.IndexReadCloser.ReadByte()
+	r.errs.record(err)
+	return b, err
+}
+
+func (r *instrumentedReader) Index(path ...uint32) (wrpc.IndexReadCloser, error) {
+	inner, err := r.IndexReadCloser.Index(path...)
+	r.errs.record(err)
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedReader(inner, r.errs, r.readers), nil
+}
+
+func (r *instrumentedReader) Close() error {
+	err := r.IndexReadCloser.Close()
+	r.errs.record(err)
+	if r.closed.CompareAndSwap(false, true) {
+		r.readers.closed()
+	}
+	return err
+}
+
+// natsHeaderCarrier adapts NATS headers to an OpenTelemetry TextMapCarrier.
+type natsHeaderCarrier nats.Header
+
+func (c natsHeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c natsHeaderCarrier) Set(key string, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
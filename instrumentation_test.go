@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	wrpc "wrpc.io/go"
+	wrpcnats "wrpc.io/go/nats"
+)
+
+// fakeInvoker records the NATS headers of invocations.
+type fakeInvoker struct {
+	header nats.Header
+	err    error
+}
+
+func (i *fakeInvoker) Invoke(ctx context.Context, instance string, name string, params []byte, paths ...wrpc.SubscribePath) (wrpc.IndexWriteCloser, wrpc.IndexReadCloser, error) {
+	i.header, _ = wrpcnats.HeaderFromContext(ctx)
+	if i.err != nil {
+		return nil, nil, i.err
+	}
+	return nil, &fakeReader{}, nil
+}
+
+type fakeReader struct{}
+
+func (r *fakeReader) Read([]byte) (int, error)                      { return 0, io.EOF }
+func (r *fakeReader) ReadByte() (byte, error)                       { return 0, io.EOF }
+func (r *fakeReader) Index(...uint32) (wrpc.IndexReadCloser, error) { return r, nil }
+func (r *fakeReader) Close() error                                  { return nil }
+
+func TestInstrumentation(t *testing.T) {
+	otel.SetTextMapPropagator(newPropagator())
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	server := &fakeServer{}
+	var handlerSpan trace.SpanContext
+	_, err := newInstrumentedServer(server, tp, mp).Serve("wrpc:keyvalue/store@0.2.0-draft", "get",
+		func(ctx context.Context, w wrpc.IndexWriteCloser, r wrpc.IndexReadCloser) {
+			handlerSpan = trace.SpanContextFromContext(ctx)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoker := &fakeInvoker{}
+	_, r, err := newInstrumentedInvoker(invoker, tp, mp).Invoke(context.Background(), "wrpc:keyvalue/store@0.2.0-draft", "get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoker.header.Get("traceparent") == "" {
+		t.Fatalf("expected the trace context in the invocation headers, got %v", invoker.header)
+	}
+
+	// Deliver the invocation with its headers to the server
+	server.handlers["wrpc:keyvalue/store@0.2.0-draft.get"](wrpcnats.ContextWithHeader(context.Background(), invoker.header), nil, nil)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(ended))
+	}
+	serverSpan, clientSpan := ended[0], ended[1]
+	if serverSpan.SpanKind() != trace.SpanKindServer || clientSpan.SpanKind() != trace.SpanKindClient {
+		t.Errorf("unexpected span kinds: %v, %v", serverSpan.SpanKind(), clientSpan.SpanKind())
+	}
+	if serverSpan.Name() != "wrpc:keyvalue/store@0.2.0-draft/get" {
+		t.Errorf("unexpected span name: %s", serverSpan.Name())
+	}
+	if serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() || serverSpan.SpanContext().TraceID() != clientSpan.SpanContext().TraceID() {
+		t.Error("expected the server span to be a child of the client span")
+	}
+	if handlerSpan.SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Error("expected the handler to run in the server span")
+	}
+
+	// A failed invocation is recorded as an error
+	invoker.err = errors.New("no responders")
+	if _, _, err := newInstrumentedInvoker(invoker, tp, mp).Invoke(context.Background(), "wrpc:keyvalue/store@0.2.0-draft", "set", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	failed := spans.Ended()[2]
+	if failed.Status().Code != codes.Error {
+		t.Errorf("expected an error status, got %v", failed.Status())
+	}
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	errorCounts := map[string]uint64{}
+	sums := map[string]int64{}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for _, point := range data.DataPoints {
+					counts[m.Name] += point.Count
+					if _, ok := point.Attributes.Value("error.type"); ok {
+						errorCounts[m.Name] += point.Count
+					}
+				}
+			case metricdata.Sum[int64]:
+				for _, point := range data.DataPoints {
+					if service, _ := point.Attributes.Value("rpc.service"); service.AsString() != "wrpc:keyvalue/store@0.2.0-draft" {
+						t.Errorf("unexpected attributes for %s: %v", m.Name, point.Attributes)
+					}
+					sums[m.Name] += point.Value
+				}
+			default:
+				t.Fatalf("unexpected data for %s: %T", m.Name, m.Data)
+			}
+		}
+	}
+	if counts["rpc.server.duration"] != 1 || counts["rpc.client.duration"] != 2 || errorCounts["rpc.client.duration"] != 1 {
+		t.Errorf("unexpected metrics: %v, errors: %v", counts, errorCounts)
+	}
+	if sums["wasmcloud.provider.rpc.invocations"] != 1 || sums["wasmcloud.provider.rpc.invocations.active"] != 0 || sums["wasmcloud.provider.rpc.invocations.errors"] != 0 {
+		t.Errorf("unexpected invocation counts: %v", sums)
+	}
+}
@@ -1,3 +1,32 @@
 // Generated by `wit-bindgen-wrpc-go` 0.9.1. DO NOT EDIT!
 // internal package contains wRPC bindings for `internal` world
 package internal
+
+import (
+	exports__wrpc__http__incoming_handler "go.wasmcloud.dev/provider/internal/exports/wrpc/http/incoming_handler"
+	exports__wrpc__http__outgoing_handler "go.wasmcloud.dev/provider/internal/exports/wrpc/http/outgoing_handler"
+	wrpc "wrpc.io/go"
+)
+
+func Serve(s wrpc.Server, h0 exports__wrpc__http__incoming_handler.Handler, h1 exports__wrpc__http__outgoing_handler.Handler) (stop func() error, err error) {
+	stops := make([]func() error, 0, 2)
+	stop = func() error {
+		for _, stop := range stops {
+			if err := stop(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	stop0, err := exports__wrpc__http__incoming_handler.ServeInterface(s, h0)
+	if err != nil {
+		return
+	}
+	stops = append(stops, stop0)
+	stop1, err := exports__wrpc__http__outgoing_handler.ServeInterface(s, h1)
+	if err != nil {
+		return
+	}
+	stops = append(stops, stop1)
+	return
+}
@@ -1,5 +1,12 @@
 package provider
 
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"sort"
+)
+
 // NOTE(brooksmtownsend): There might be a better way to represent this in Go, please comment
 // or leave an issue if you can think of one. Perhaps I could do the decryption during the
 // unmarshalling process, but I'm not sure if that would be a good idea.
@@ -29,3 +36,117 @@ type InterfaceLinkDefinition struct {
 	SourceSecrets map[string]SecretValue `json:"source_secrets,omitempty"`
 	TargetSecrets map[string]SecretValue `json:"target_secrets,omitempty"`
 }
+
+// linkResponse is the reply to link puts and deletes sent as requests.
+type linkResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+// clone returns a deep copy of the link definition.
+func (l InterfaceLinkDefinition) clone() InterfaceLinkDefinition {
+	l.Interfaces = slices.Clone(l.Interfaces)
+	l.SourceConfig = maps.Clone(l.SourceConfig)
+	l.TargetConfig = maps.Clone(l.TargetConfig)
+	l.SourceSecrets = maps.Clone(l.SourceSecrets)
+	l.TargetSecrets = maps.Clone(l.TargetSecrets)
+	return l
+}
+
+// equal reports whether two link definitions have the same content, including secrets.
+func (l InterfaceLinkDefinition) equal(other InterfaceLinkDefinition) bool {
+	return l.equalExceptSecrets(other) &&
+		maps.EqualFunc(l.SourceSecrets, other.SourceSecrets, secretValuesEqual) &&
+		maps.EqualFunc(l.TargetSecrets, other.TargetSecrets, secretValuesEqual)
+}
+
+// equalExceptSecrets reports whether two link definitions have the same content, ignoring
+// their secrets.
+func (l InterfaceLinkDefinition) equalExceptSecrets(other InterfaceLinkDefinition) bool {
+	return l.SourceID == other.SourceID &&
+		l.Target == other.Target &&
+		l.Name == other.Name &&
+		l.WitNamespace == other.WitNamespace &&
+		l.WitPackage == other.WitPackage &&
+		slices.Equal(l.Interfaces, other.Interfaces) &&
+		maps.Equal(l.SourceConfig, other.SourceConfig) &&
+		maps.Equal(l.TargetConfig, other.TargetConfig)
+}
+
+func secretValuesEqual(a SecretValue, b SecretValue) bool {
+	return a.Kind() == b.Kind() && a.String.value == b.String.value && bytes.Equal(a.Bytes.value, b.Bytes.value)
+}
+
+// redacted returns a copy of the link definition without its secrets, safe to send
+// over the lattice or log.
+func (l InterfaceLinkDefinition) redacted() InterfaceLinkDefinition {
+	l = l.clone()
+	l.SourceSecrets = nil
+	l.TargetSecrets = nil
+	return l
+}
+
+// linkKey uniquely identifies a link from the provider's point of view. Multiple links can
+// exist between the provider and a component as long as their name or WIT package differ.
+type linkKey struct {
+	// The component ID on the other end of the link
+	component    string
+	name         string
+	witNamespace string
+	witPackage   string
+}
+
+// sourceLinkKey returns the key of a link where the provider is the source.
+func sourceLinkKey(l InterfaceLinkDefinition) linkKey {
+	return linkKey{component: l.Target, name: l.Name, witNamespace: l.WitNamespace, witPackage: l.WitPackage}
+}
+
+// targetLinkKey returns the key of a link where the provider is the target.
+func targetLinkKey(l InterfaceLinkDefinition) linkKey {
+	return linkKey{component: l.SourceID, name: l.Name, witNamespace: l.WitNamespace, witPackage: l.WitPackage}
+}
+
+// sortedLinks returns a copy of the links in a map, sorted by source, target, name and WIT package.
+func sortedLinks(links map[linkKey]InterfaceLinkDefinition) []InterfaceLinkDefinition {
+	sorted := make([]InterfaceLinkDefinition, 0, len(links))
+	for _, link := range links {
+		sorted = append(sorted, link.clone())
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.SourceID != b.SourceID {
+			return a.SourceID < b.SourceID
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.WitNamespace != b.WitNamespace {
+			return a.WitNamespace < b.WitNamespace
+		}
+		return a.WitPackage < b.WitPackage
+	})
+	return sorted
+}
+
+// findLink returns a copy of the first link (ordered by WIT namespace and package) to or from
+// component with the given name.
+func findLink(links map[linkKey]InterfaceLinkDefinition, component string, name string) (InterfaceLinkDefinition, bool) {
+	var found *linkKey
+	for key := range links {
+		if key.component != component || key.name != name {
+			continue
+		}
+		if found == nil || key.witNamespace < found.witNamespace ||
+			(key.witNamespace == found.witNamespace && key.witPackage < found.witPackage) {
+			key := key
+			found = &key
+		}
+	}
+	if found == nil {
+		return InterfaceLinkDefinition{}, false
+	}
+	return links[*found].clone(), true
+}
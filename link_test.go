@@ -0,0 +1,302 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLinks(t *testing.T) {
+	wp := newTestProvider(t)
+
+	sourceLink := InterfaceLinkDefinition{
+		SourceID:      wp.Id,
+		Target:        "component",
+		Name:          "default",
+		WitNamespace:  "wrpc",
+		WitPackage:    "keyvalue",
+		Interfaces:    []string{"store"},
+		SourceConfig:  map[string]string{"bucket": "default"},
+		SourceSecrets: map[string]SecretValue{"password": {String: SecretStringValue{value: "hunter2"}}},
+	}
+	targetLink := InterfaceLinkDefinition{
+		SourceID:      "component",
+		Target:        wp.Id,
+		Name:          "default",
+		WitNamespace:  "wasi",
+		WitPackage:    "http",
+		Interfaces:    []string{"incoming-handler"},
+		TargetConfig:  map[string]string{"address": "0.0.0.0:8080"},
+		TargetSecrets: map[string]SecretValue{"token": {String: SecretStringValue{value: "s3cr3t"}}},
+	}
+	for _, link := range []InterfaceLinkDefinition{sourceLink, targetLink} {
+		if err := wp.putLink(link); err != nil {
+			t.Fatalf("Expected err to be nil, got: %v", err)
+		}
+	}
+
+	if links := wp.Links(); len(links) != 2 {
+		t.Fatalf("Expected 2 links, got %d", len(links))
+	}
+
+	sourceLinks := wp.SourceLinks()
+	if len(sourceLinks) != 1 || sourceLinks[0].Target != "component" {
+		t.Fatalf("Expected a single source link to component, got: %+v", sourceLinks)
+	}
+	if sourceLinks[0].SourceSecrets["password"].String.Reveal() != "hunter2" {
+		t.Error("Expected source links to include secrets")
+	}
+
+	// Mutating the returned links must not affect the provider
+	sourceLinks[0].SourceConfig["bucket"] = "changed"
+	sourceLinks[0].Interfaces[0] = "changed"
+	if link := wp.SourceLinks()[0]; link.SourceConfig["bucket"] != "default" || link.Interfaces[0] != "store" {
+		t.Errorf("Expected SourceLinks to return a copy, got: %+v", link)
+	}
+
+	targetLinks := wp.TargetLinks()
+	if len(targetLinks) != 1 || targetLinks[0].SourceID != "component" {
+		t.Fatalf("Expected a single target link from component, got: %+v", targetLinks)
+	}
+
+	if err := wp.subToNats(); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	msg, err := wp.NatsConnection().Request(wp.Topics.LATTICE_LINK_GET, nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	var links []InterfaceLinkDefinition
+	if err := json.Unmarshal(msg.Data, &links); err != nil {
+		t.Fatalf("Failed to decode links: %v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links, got %d", len(links))
+	}
+	for _, link := range links {
+		if link.SourceSecrets != nil || link.TargetSecrets != nil {
+			t.Errorf("Expected secrets to be redacted, got: %+v", link)
+		}
+	}
+	if links[0].SourceConfig["bucket"] != "default" || links[1].TargetConfig["address"] != "0.0.0.0:8080" {
+		t.Errorf("Expected link config to be returned, got: %+v", links)
+	}
+}
+
+func TestMultipleLinksBetweenComponents(t *testing.T) {
+	var puts, dels int
+	wp := newTestProvider(t,
+		TargetLinkPut(func(InterfaceLinkDefinition) error { puts++; return nil }),
+		TargetLinkDel(func(InterfaceLinkDefinition) error { dels++; return nil }),
+	)
+
+	link := func(name string, witPackage string) InterfaceLinkDefinition {
+		return InterfaceLinkDefinition{
+			SourceID:     "component",
+			Target:       wp.Id,
+			Name:         name,
+			WitNamespace: "wrpc",
+			WitPackage:   witPackage,
+			Interfaces:   []string{"store"},
+			TargetConfig: map[string]string{"link": name + "/" + witPackage},
+		}
+	}
+
+	for _, l := range []InterfaceLinkDefinition{
+		link("default", "keyvalue"),
+		link("analytics", "keyvalue"),
+		link("default", "blobstore"),
+		// Exact duplicate, should be ignored
+		link("analytics", "keyvalue"),
+	} {
+		if err := wp.putLink(l); err != nil {
+			t.Fatalf("Expected err to be nil, got: %v", err)
+		}
+	}
+
+	if puts != 3 {
+		t.Errorf("Expected the target link put handler to be called 3 times, got %d", puts)
+	}
+	if links := wp.TargetLinks(); len(links) != 3 {
+		t.Fatalf("Expected 3 target links, got %d: %+v", len(links), links)
+	}
+
+	analytics, ok := wp.LinkFor("component", "analytics")
+	if !ok || analytics.TargetConfig["link"] != "analytics/keyvalue" {
+		t.Errorf("Expected to find the analytics link, got: %+v", analytics)
+	}
+	// Links with the same name are ordered by WIT namespace and package
+	defaultLink, ok := wp.LinkFor("component", "default")
+	if !ok || defaultLink.TargetConfig["link"] != "default/blobstore" {
+		t.Errorf("Expected to find the default blobstore link, got: %+v", defaultLink)
+	}
+	if _, ok := wp.LinkFor("other-component", "default"); ok {
+		t.Error("Expected no link from other-component")
+	}
+	if _, ok := wp.SourceLinkFor("component", "default"); ok {
+		t.Error("Expected no source link to component")
+	}
+
+	if err := wp.deleteLink(link("default", "blobstore")); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	if dels != 1 {
+		t.Errorf("Expected the target link delete handler to be called once, got %d", dels)
+	}
+
+	links := wp.TargetLinks()
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 target links after delete, got %d: %+v", len(links), links)
+	}
+	defaultLink, ok = wp.LinkFor("component", "default")
+	if !ok || defaultLink.TargetConfig["link"] != "default/keyvalue" {
+		t.Errorf("Expected the default keyvalue link to remain, got: %+v", defaultLink)
+	}
+	if _, ok := wp.LinkFor("component", "analytics"); !ok {
+		t.Error("Expected the analytics link to remain")
+	}
+}
+
+func TestLinkUpdate(t *testing.T) {
+	secret := func(v string) map[string]SecretValue {
+		return map[string]SecretValue{"password": {String: SecretStringValue{value: v}}}
+	}
+	link := InterfaceLinkDefinition{
+		SourceID:      "component",
+		Target:        "provider",
+		Name:          "default",
+		WitNamespace:  "wasmcloud",
+		WitPackage:    "postgres",
+		TargetConfig:  map[string]string{"host": "db-1"},
+		TargetSecrets: secret("first"),
+	}
+	rotated := link.clone()
+	rotated.TargetSecrets = secret("second")
+
+	t.Run("update handler", func(t *testing.T) {
+		var puts, dels int
+		var updates [][2]InterfaceLinkDefinition
+		wp := newTestProvider(t,
+			TargetLinkPut(func(InterfaceLinkDefinition) error { puts++; return nil }),
+			TargetLinkDel(func(InterfaceLinkDefinition) error { dels++; return nil }),
+			TargetLinkUpdate(func(old, new InterfaceLinkDefinition) error {
+				updates = append(updates, [2]InterfaceLinkDefinition{old, new})
+				return nil
+			}),
+		)
+
+		for _, l := range []InterfaceLinkDefinition{link, link.clone(), rotated} {
+			if err := wp.putLink(l); err != nil {
+				t.Fatalf("Expected err to be nil, got: %v", err)
+			}
+		}
+
+		if puts != 1 || dels != 0 {
+			t.Errorf("Expected 1 put and 0 deletes, got %d puts and %d deletes", puts, dels)
+		}
+		if len(updates) != 1 {
+			t.Fatalf("Expected 1 update, got %d", len(updates))
+		}
+		if updates[0][0].TargetSecrets["password"].String.Reveal() != "first" ||
+			updates[0][1].TargetSecrets["password"].String.Reveal() != "second" {
+			t.Errorf("Expected update to receive the old and new secrets, got: %+v", updates[0])
+		}
+		if l, _ := wp.LinkFor("component", "default"); l.TargetSecrets["password"].String.Reveal() != "second" {
+			t.Error("Expected the updated link to be stored")
+		}
+	})
+
+	t.Run("handlers look up links", func(t *testing.T) {
+		var wp *WasmcloudProvider
+		var found InterfaceLinkDefinition
+		wp = newTestProvider(t,
+			TargetLinkUpdate(func(old, new InterfaceLinkDefinition) error {
+				// The handlers are called without holding the provider lock
+				found, _ = wp.LinkFor("component", "default")
+				return nil
+			}),
+		)
+
+		for _, l := range []InterfaceLinkDefinition{link, rotated} {
+			if err := wp.putLink(l); err != nil {
+				t.Fatalf("Expected err to be nil, got: %v", err)
+			}
+		}
+		if found.TargetSecrets["password"].String.Reveal() != "first" {
+			t.Error("Expected the handler to find the old link")
+		}
+	})
+
+	t.Run("rejected update", func(t *testing.T) {
+		wp := newTestProvider(t,
+			TargetLinkUpdate(func(old, new InterfaceLinkDefinition) error {
+				return errors.New("rejected")
+			}),
+		)
+
+		if err := wp.putLink(link); err != nil {
+			t.Fatalf("Expected err to be nil, got: %v", err)
+		}
+		if err := wp.putLink(rotated); err == nil {
+			t.Fatal("Expected the update to fail")
+		}
+		if l, ok := wp.LinkFor("component", "default"); !ok || l.TargetSecrets["password"].String.Reveal() != "first" {
+			t.Error("Expected the old link to be kept")
+		}
+	})
+
+	t.Run("delete and put fallback", func(t *testing.T) {
+		var calls []string
+		wp := newTestProvider(t,
+			TargetLinkPut(func(l InterfaceLinkDefinition) error {
+				calls = append(calls, "put:"+l.TargetSecrets["password"].String.Reveal())
+				return nil
+			}),
+			TargetLinkDel(func(l InterfaceLinkDefinition) error {
+				calls = append(calls, "del:"+l.TargetSecrets["password"].String.Reveal())
+				return nil
+			}),
+		)
+
+		changedConfig := rotated.clone()
+		changedConfig.TargetConfig = map[string]string{"host": "db-2"}
+		for _, l := range []InterfaceLinkDefinition{link, rotated, changedConfig} {
+			if err := wp.putLink(l); err != nil {
+				t.Fatalf("Expected err to be nil, got: %v", err)
+			}
+		}
+
+		want := []string{"put:first", "del:first", "put:second", "del:second", "put:second"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+		if l, _ := wp.LinkFor("component", "default"); l.TargetConfig["host"] != "db-2" {
+			t.Error("Expected the updated link to be stored")
+		}
+	})
+
+	t.Run("failed put after delete", func(t *testing.T) {
+		wp := newTestProvider(t,
+			TargetLinkPut(func(l InterfaceLinkDefinition) error {
+				if l.TargetSecrets["password"].String.Reveal() == "second" {
+					return errors.New("invalid credentials")
+				}
+				return nil
+			}),
+		)
+
+		if err := wp.putLink(link); err != nil {
+			t.Fatalf("Expected err to be nil, got: %v", err)
+		}
+		if err := wp.putLink(rotated); err == nil {
+			t.Fatal("Expected the update to fail")
+		}
+		if _, ok := wp.LinkFor("component", "default"); ok {
+			t.Error("Expected the link to be removed after its delete succeeded")
+		}
+	})
+}
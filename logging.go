@@ -1,10 +1,19 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"slices"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+	wrpcnats "wrpc.io/go/nats"
 )
 
 type Level string
@@ -22,6 +31,12 @@ func (l Level) String() string {
 	return string(l)
 }
 
+// slog levels for the trace and critical levels, which slog doesn't define
+const (
+	LevelTrace    = slog.Level(-8)
+	LevelCritical = slog.Level(12)
+)
+
 func (l Level) Level() slog.Level {
 	switch l {
 	case Error:
@@ -32,23 +47,87 @@ func (l Level) Level() slog.Level {
 		return slog.LevelInfo
 	case Debug:
 		return slog.LevelDebug
-	// NOTE: slog doesn't have trace/critical levels so we map them to debug/error
 	case Trace:
-		return slog.LevelDebug
+		return LevelTrace
 	case Critical:
-		return slog.LevelError
+		return LevelCritical
 	default:
 		return slog.LevelInfo
 	}
 }
 
+// levelFromSlog returns the closest Level to a slog level.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level <= LevelTrace:
+		return Trace
+	case level <= slog.LevelDebug:
+		return Debug
+	case level <= slog.LevelInfo:
+		return Info
+	case level <= slog.LevelWarn:
+		return Warn
+	case level <= slog.LevelError:
+		return Error
+	default:
+		return Critical
+	}
+}
+
+// levelName returns the name of a slog level, naming the trace and critical levels instead
+// of formatting them relative to debug and error (ex: "DEBUG-4").
+func levelName(level slog.Level) string {
+	switch level {
+	case LevelTrace:
+		return "TRACE"
+	case LevelCritical:
+		return "CRITICAL"
+	default:
+		return level.String()
+	}
+}
+
+// replaceLevelName is a slog.HandlerOptions.ReplaceAttr function naming the trace and
+// critical levels.
+func replaceLevelName(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && attr.Key == slog.LevelKey {
+		if level, ok := attr.Value.Any().(slog.Level); ok {
+			attr.Value = slog.StringValue(levelName(level))
+		}
+	}
+	return attr
+}
+
+// SetLogLevel changes the level of the provider's logger at runtime.
+func (wp *WasmcloudProvider) SetLogLevel(level Level) error {
+	if err := level.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	wp.logLevel.Set(level.Level())
+	wp.Logger.Info("log level changed", "level", level.String())
+	return nil
+}
+
+// LogLevel returns the current level of the provider's logger.
+func (wp *WasmcloudProvider) LogLevel() Level {
+	return levelFromSlog(wp.logLevel.Level())
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l), nil
+}
+
 func (l *Level) UnmarshalJSON(data []byte) error {
 	var s string
 	err := json.Unmarshal(data, &s)
 	if err != nil {
 		return err
 	}
+	return l.UnmarshalText([]byte(s))
+}
 
+func (l *Level) UnmarshalText(data []byte) error {
+	s := string(data)
 	switch strings.ToLower(s) {
 	case "error":
 		*l = Error
@@ -68,3 +147,259 @@ func (l *Level) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// Headers set by the host on invocations from components
+const (
+	sourceIDHeader = "source-id"
+	linkNameHeader = "link-name"
+)
+
+type loggerKey struct{}
+
+// LoggerFromContext returns the logger of a wRPC invocation served with RPCServer, with the
+// invoked interface and function, as well as the source component and link name sent by the
+// host, as attributes. Outside of invocations, the provider logger is returned for contexts
+// derived from the provider's, ex: the one of the health check, and slog.Default() for others.
+// See WasmcloudProvider.LoggerFromContext to always fall back to the provider logger.
+//
+// Log with the *Context methods (ex: InfoContext) to include the trace and span IDs.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// LoggerFromContext returns the logger of a wRPC invocation, like the LoggerFromContext
+// function, falling back to the provider Logger outside of invocations.
+func (wp *WasmcloudProvider) LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return wp.Logger
+}
+
+// contextWithInvocationLogger returns a context holding a logger for an invocation of
+// instance.name, see LoggerFromContext.
+func contextWithInvocationLogger(ctx context.Context, logger *slog.Logger, instance string, name string) context.Context {
+	attrs := []any{slog.String("interface", instance), slog.String("function", name)}
+	if header, ok := wrpcnats.HeaderFromContext(ctx); ok {
+		if sourceID := header.Get(sourceIDHeader); sourceID != "" {
+			attrs = append(attrs, slog.String("source_id", sourceID))
+		}
+		if linkName := header.Get(linkNameHeader); linkName != "" {
+			attrs = append(attrs, slog.String("link_name", linkName))
+		}
+	}
+	return context.WithValue(ctx, loggerKey{}, logger.With(attrs...))
+}
+
+// traceContextHandler adds the trace and span IDs found in the context to records.
+type traceContextHandler struct {
+	slog.Handler
+}
+
+func (h traceContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record = record.Clone()
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h traceContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceContextHandler) WithGroup(name string) slog.Handler {
+	return traceContextHandler{h.Handler.WithGroup(name)}
+}
+
+// fanoutHandler sends records to multiple handlers.
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+// otelLogHandler emits records to an OpenTelemetry logger. The trace and span IDs are taken
+// from the context by the logger.
+type otelLogHandler struct {
+	logger log.Logger
+	level  slog.Leveler
+	// Groups and attributes added with WithGroup and WithAttrs, in order
+	prefix []groupOrAttrs
+}
+
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func newOtelLogHandler(logger log.Logger, level slog.Leveler) *otelLogHandler {
+	return &otelLogHandler{logger: logger, level: level}
+}
+
+func (h *otelLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *otelLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	var r log.Record
+	r.SetTimestamp(record.Time)
+	r.SetObservedTimestamp(time.Now())
+	r.SetBody(log.StringValue(record.Message))
+	r.SetSeverity(otelSeverity(record.Level))
+	r.SetSeverityText(levelName(record.Level))
+
+	attrs := make([]log.KeyValue, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = appendOtelAttr(attrs, attr)
+		return true
+	})
+	// Nest the record attributes in the groups, from the innermost one
+	for i := len(h.prefix) - 1; i >= 0; i-- {
+		entry := h.prefix[i]
+		if entry.group != "" {
+			if len(attrs) > 0 {
+				attrs = []log.KeyValue{log.Map(entry.group, attrs...)}
+			}
+			continue
+		}
+		prefixAttrs := make([]log.KeyValue, 0, len(entry.attrs)+len(attrs))
+		for _, attr := range entry.attrs {
+			prefixAttrs = appendOtelAttr(prefixAttrs, attr)
+		}
+		attrs = append(prefixAttrs, attrs...)
+	}
+	r.AddAttributes(attrs...)
+
+	h.logger.Emit(ctx, r)
+	return nil
+}
+
+func (h *otelLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+func (h *otelLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *otelLogHandler) with(entry groupOrAttrs) *otelLogHandler {
+	return &otelLogHandler{
+		logger: h.logger,
+		level:  h.level,
+		prefix: append(slices.Clip(h.prefix), entry),
+	}
+}
+
+// otelSeverity maps slog levels to OpenTelemetry severities, ex: slog.LevelInfo (0) to
+// log.SeverityInfo (9).
+func otelSeverity(level slog.Level) log.Severity {
+	severity := int(level) + int(log.SeverityInfo)
+	return log.Severity(min(max(severity, int(log.SeverityTrace1)), int(log.SeverityFatal4)))
+}
+
+func appendOtelAttr(attrs []log.KeyValue, attr slog.Attr) []log.KeyValue {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		group := attr.Value.Group()
+		if len(group) == 0 {
+			return attrs
+		}
+		// Groups without a key are inlined, like slog handlers do
+		if attr.Key == "" {
+			for _, groupAttr := range group {
+				attrs = appendOtelAttr(attrs, groupAttr)
+			}
+			return attrs
+		}
+	}
+	return append(attrs, log.KeyValue{Key: attr.Key, Value: otelValue(attr.Value)})
+}
+
+func otelValue(value slog.Value) log.Value {
+	switch value.Kind() {
+	case slog.KindString:
+		return log.StringValue(value.String())
+	case slog.KindInt64:
+		return log.Int64Value(value.Int64())
+	case slog.KindUint64:
+		if v := value.Uint64(); v <= math.MaxInt64 {
+			return log.Int64Value(int64(v))
+		}
+		return log.StringValue(value.String())
+	case slog.KindFloat64:
+		return log.Float64Value(value.Float64())
+	case slog.KindBool:
+		return log.BoolValue(value.Bool())
+	case slog.KindDuration:
+		return log.Int64Value(int64(value.Duration()))
+	case slog.KindTime:
+		return log.StringValue(value.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		attrs := make([]log.KeyValue, 0, len(value.Group()))
+		for _, attr := range value.Group() {
+			attrs = appendOtelAttr(attrs, attr)
+		}
+		return log.MapValue(attrs...)
+	}
+
+	switch v := value.Any().(type) {
+	case error:
+		return log.StringValue(v.Error())
+	case []byte:
+		return log.BytesValue(v)
+	case fmt.Stringer:
+		return log.StringValue(v.String())
+	default:
+		return log.StringValue(fmt.Sprintf("%+v", v))
+	}
+}
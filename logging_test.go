@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	wrpcnats "wrpc.io/go/nats"
+)
+
+// recordingExporter keeps the exported log records in memory.
+type recordingExporter struct {
+	lock    sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *recordingExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, record := range records {
+		e.records = append(e.records, record.Clone())
+	}
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error   { return nil }
+func (e *recordingExporter) ForceFlush(context.Context) error { return nil }
+
+func TestOtelLogHandler(t *testing.T) {
+	exporter := &recordingExporter{}
+	loggerProvider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+
+	var stderr bytes.Buffer
+	logger := slog.New(fanoutHandler{
+		traceContextHandler{slog.NewTextHandler(&stderr, nil)},
+		newOtelLogHandler(loggerProvider.Logger("test"), Info),
+	})
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	logger.Debug("filtered")
+	logger.With("component", "kv").WithGroup("request").With("bucket", "default").
+		WarnContext(ctx, "slow request", "key", "k1", "password", NewSecretString("hunter2"))
+
+	if len(exporter.records) != 1 {
+		t.Fatalf("expected 1 exported record, got %d", len(exporter.records))
+	}
+	record := exporter.records[0]
+	if record.Body().AsString() != "slow request" || record.Severity() != log.SeverityWarn || record.SeverityText() != "WARN" {
+		t.Errorf("unexpected record: %q %v %q", record.Body().AsString(), record.Severity(), record.SeverityText())
+	}
+	if record.TraceID() != span.SpanContext().TraceID() || record.SpanID() != span.SpanContext().SpanID() {
+		t.Error("expected the trace and span IDs from the context")
+	}
+
+	attrs := map[string]log.Value{}
+	record.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	if attrs["component"].AsString() != "kv" {
+		t.Errorf("expected the component attribute, got %v", attrs)
+	}
+	request := map[string]string{}
+	for _, kv := range attrs["request"].AsMap() {
+		request[kv.Key] = kv.Value.String()
+	}
+	if request["bucket"] != "default" || request["key"] != "k1" || request["password"] != "redacted(string)" {
+		t.Errorf("unexpected request group: %v", request)
+	}
+
+	output := stderr.String()
+	if !strings.Contains(output, "trace_id="+span.SpanContext().TraceID().String()) || strings.Contains(output, "hunter2") {
+		t.Errorf("unexpected stderr output: %s", output)
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger outside of invocations")
+	}
+	wp := newTestProvider(t)
+	if LoggerFromContext(wp.context) != wp.Logger || wp.LoggerFromContext(context.Background()) != wp.Logger {
+		t.Error("expected the provider logger outside of invocations")
+	}
+
+	var buf bytes.Buffer
+	header := nats.Header{}
+	header.Set(sourceIDHeader, "component-a")
+	header.Set(linkNameHeader, "default")
+	ctx := wrpcnats.ContextWithHeader(context.Background(), header)
+	ctx = contextWithInvocationLogger(ctx, slog.New(slog.NewTextHandler(&buf, nil)), "wrpc:keyvalue/store@0.2.0-draft", "get")
+
+	LoggerFromContext(ctx).Info("invoked")
+	for _, expected := range []string{"source_id=component-a", "link_name=default", "function=get"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in %q", expected, buf.String())
+		}
+	}
+	if wp.LoggerFromContext(ctx) != LoggerFromContext(ctx) {
+		t.Error("expected the invocation logger in invocations")
+	}
+}
+
+func TestLevelNames(t *testing.T) {
+	if Trace.Level() >= slog.LevelDebug || Critical.Level() <= slog.LevelError {
+		t.Errorf("expected trace below debug and critical above error, got %v and %v", Trace.Level(), Critical.Level())
+	}
+	if otelSeverity(Trace.Level()) != log.SeverityTrace1 || otelSeverity(Critical.Level()) != log.SeverityFatal1 {
+		t.Error("unexpected OpenTelemetry severities")
+	}
+
+	var buf bytes.Buffer
+	options := &slog.HandlerOptions{Level: Trace, ReplaceAttr: replaceLevelName}
+	text := slog.New(slog.NewTextHandler(&buf, options))
+	text.Log(context.Background(), Trace.Level(), "tracing")
+	text.Log(context.Background(), Critical.Level(), "on fire")
+	if !strings.Contains(buf.String(), "level=TRACE") || !strings.Contains(buf.String(), "level=CRITICAL") {
+		t.Errorf("unexpected text output: %s", buf.String())
+	}
+
+	buf.Reset()
+	json := slog.New(slog.NewJSONHandler(&buf, options))
+	json.Log(context.Background(), Critical.Level(), "on fire")
+	if !strings.Contains(buf.String(), `"level":"CRITICAL"`) {
+		t.Errorf("unexpected json output: %s", buf.String())
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	wp := newTestProvider(t)
+	ctx := context.Background()
+	if wp.LogLevel() != Info || wp.Logger.Enabled(ctx, slog.LevelDebug) {
+		t.Fatalf("expected the info level by default, got %s", wp.LogLevel())
+	}
+
+	if err := wp.SetLogLevel("TRACE"); err != nil {
+		t.Fatal(err)
+	}
+	if wp.LogLevel() != Trace || !wp.Logger.Enabled(ctx, Trace.Level()) {
+		t.Errorf("expected the trace level, got %s", wp.LogLevel())
+	}
+
+	if err := wp.SetLogLevel(Critical); err != nil {
+		t.Fatal(err)
+	}
+	if wp.Logger.Enabled(ctx, slog.LevelError) {
+		t.Error("expected errors to be filtered at the critical level")
+	}
+
+	if err := wp.SetLogLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if wp.LogLevel() != Critical {
+		t.Errorf("expected the level to be unchanged, got %s", wp.LogLevel())
+	}
+}
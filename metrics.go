@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Attribute values of the built-in provider metrics
+const (
+	linkDirectionSource = "source"
+	linkDirectionTarget = "target"
+	linkOperationPut    = "put"
+	linkOperationDelete = "delete"
+)
+
+// providerMetrics holds the built-in instruments of the provider, registered with the global
+// MeterProvider so dashboards can be shared across providers:
+//
+//   - wasmcloud.provider.links: current number of links, by direction
+//   - wasmcloud.provider.link.events: link puts and deletes, by direction and operation
+//   - wasmcloud.provider.link.failures: link puts and deletes whose handler failed
+//   - wasmcloud.provider.health_check.duration: duration of health checks, by result
+//   - wasmcloud.provider.nats.reconnects: reconnections to the lattice
+//   - wasmcloud.provider.nats.bytes and wasmcloud.provider.nats.messages: lattice traffic, by direction
+//
+// wRPC invocations are measured by the instrumented RPCServer, see InstrumentServer.
+type providerMetrics struct {
+	linkEvents          metric.Int64Counter
+	linkFailures        metric.Int64Counter
+	healthCheckDuration metric.Float64Histogram
+
+	registration metric.Registration
+}
+
+// newProviderMetrics creates the instruments and starts observing wp, whose lattice
+// connection must be established.
+func newProviderMetrics(mp metric.MeterProvider, wp *WasmcloudProvider) *providerMetrics {
+	meter := mp.Meter(instrumentationName)
+	m := &providerMetrics{}
+	var errs []error
+	var err error
+
+	m.linkEvents, err = meter.Int64Counter("wasmcloud.provider.link.events",
+		metric.WithDescription("Link puts and deletes received by the provider"))
+	errs = append(errs, err)
+	m.linkFailures, err = meter.Int64Counter("wasmcloud.provider.link.failures",
+		metric.WithDescription("Link puts and deletes whose handler failed"))
+	errs = append(errs, err)
+	m.healthCheckDuration, err = meter.Float64Histogram("wasmcloud.provider.health_check.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Duration of the provider health checks"))
+	errs = append(errs, err)
+
+	links, err := meter.Int64ObservableGauge("wasmcloud.provider.links",
+		metric.WithDescription("Current number of links of the provider"))
+	errs = append(errs, err)
+	reconnects, err := meter.Int64ObservableCounter("wasmcloud.provider.nats.reconnects",
+		metric.WithDescription("Reconnections to the lattice"))
+	errs = append(errs, err)
+	natsBytes, err := meter.Int64ObservableCounter("wasmcloud.provider.nats.bytes",
+		metric.WithUnit("By"),
+		metric.WithDescription("Bytes exchanged with the lattice"))
+	errs = append(errs, err)
+	natsMessages, err := meter.Int64ObservableCounter("wasmcloud.provider.nats.messages",
+		metric.WithDescription("Messages exchanged with the lattice"))
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		otel.Handle(err)
+	}
+
+	source := metric.WithAttributes(attribute.String("direction", linkDirectionSource))
+	target := metric.WithAttributes(attribute.String("direction", linkDirectionTarget))
+	in := metric.WithAttributes(attribute.String("direction", "in"))
+	out := metric.WithAttributes(attribute.String("direction", "out"))
+	m.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		wp.lock.Lock()
+		sourceLinks, targetLinks := len(wp.sourceLinks), len(wp.targetLinks)
+		wp.lock.Unlock()
+		o.ObserveInt64(links, int64(sourceLinks), source)
+		o.ObserveInt64(links, int64(targetLinks), target)
+
+		stats := wp.natsConnection.Stats()
+		o.ObserveInt64(reconnects, int64(stats.Reconnects))
+		o.ObserveInt64(natsBytes, int64(stats.InBytes), in)
+		o.ObserveInt64(natsBytes, int64(stats.OutBytes), out)
+		o.ObserveInt64(natsMessages, int64(stats.InMsgs), in)
+		o.ObserveInt64(natsMessages, int64(stats.OutMsgs), out)
+		return nil
+	}, links, reconnects, natsBytes, natsMessages)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return m
+}
+
+// recordLinkEvent records a link put or delete, and its failure if err isn't nil. Links
+// that aren't for this provider are ignored.
+func (m *providerMetrics) recordLinkEvent(ctx context.Context, direction string, operation string, err error) {
+	if direction == "" {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("direction", direction),
+		attribute.String("operation", operation),
+	)
+	m.linkEvents.Add(ctx, 1, attrs)
+	if err != nil {
+		m.linkFailures.Add(ctx, 1, attrs)
+	}
+}
+
+func (m *providerMetrics) recordHealthCheck(ctx context.Context, start time.Time, healthy bool) {
+	m.healthCheckDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond),
+		metric.WithAttributes(attribute.Bool("healthy", healthy)))
+}
+
+// unregister stops observing the provider, on shutdown.
+func (m *providerMetrics) unregister(context.Context) error {
+	if m.registration == nil {
+		return nil
+	}
+	return m.registration.Unregister()
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProviderMetrics(t *testing.T) {
+	failing := InterfaceLinkDefinition{SourceID: "component", Name: "broken"}
+	wp := newTestProvider(t, TargetLinkPut(func(l InterfaceLinkDefinition) error {
+		if l.Name == failing.Name {
+			return errors.New("invalid config")
+		}
+		return nil
+	}))
+	reader := sdkmetric.NewManualReader()
+	wp.metrics = newProviderMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), wp)
+	failing.Target = wp.Id
+
+	links := []InterfaceLinkDefinition{
+		{SourceID: wp.Id, Target: "component", Name: "default"},
+		{SourceID: "component", Target: wp.Id, Name: "default"},
+		{SourceID: "component", Target: wp.Id, Name: "other"},
+	}
+	for _, l := range links {
+		if err := wp.putLink(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wp.putLink(failing); err == nil {
+		t.Fatal("expected the link put to fail")
+	}
+	if err := wp.deleteLink(links[2]); err != nil {
+		t.Fatal(err)
+	}
+	if resp := wp.healthCheck(context.Background()); !resp.Healthy {
+		t.Fatalf("expected the provider to be healthy, got %+v", resp)
+	}
+
+	if err := wp.NatsConnection().Publish("test", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wp.NatsConnection().Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]int64{}
+	var healthChecks uint64
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, point := range data.DataPoints {
+					values[m.Name+" "+attributesKey(point.Attributes)] += point.Value
+				}
+			case metricdata.Gauge[int64]:
+				for _, point := range data.DataPoints {
+					values[m.Name+" "+attributesKey(point.Attributes)] = point.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, point := range data.DataPoints {
+					healthChecks += point.Count
+				}
+			}
+		}
+	}
+
+	expected := map[string]int64{
+		"wasmcloud.provider.links direction=source":                          1,
+		"wasmcloud.provider.links direction=target":                          1,
+		"wasmcloud.provider.link.events direction=source,operation=put":      1,
+		"wasmcloud.provider.link.events direction=target,operation=put":      3,
+		"wasmcloud.provider.link.events direction=target,operation=delete":   1,
+		"wasmcloud.provider.link.failures direction=target,operation=put":    1,
+		"wasmcloud.provider.link.failures direction=target,operation=delete": 0,
+		"wasmcloud.provider.nats.reconnects ":                                0,
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("expected %s to be %d, got %d", key, value, values[key])
+		}
+	}
+	if values["wasmcloud.provider.nats.bytes direction=out"] != 5 || values["wasmcloud.provider.nats.messages direction=out"] != 1 {
+		t.Errorf("expected bytes to be sent to the lattice, got %v", values)
+	}
+	if healthChecks != 1 {
+		t.Errorf("expected 1 health check, got %d", healthChecks)
+	}
+
+	if err := wp.metrics.unregister(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func attributesKey(set attribute.Set) string {
+	return set.Encoded(attribute.DefaultEncoder())
+}
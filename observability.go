@@ -2,9 +2,16 @@ package provider
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
@@ -19,6 +26,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/grpc/credentials"
 )
 
 const (
@@ -38,15 +46,40 @@ func newPropagator() propagation.TextMapPropagator {
 	)
 }
 
-func newTracerProvider(ctx context.Context, config OtelConfig, serviceResource *resource.Resource) (*trace.TracerProvider, error) {
+func newTracerProvider(ctx context.Context, config OtelConfig, settings otelSettings, serviceResource *resource.Resource) (*trace.TracerProvider, error) {
 	var exporter trace.SpanExporter
 	var err error
 
+	exporterSettings := settings.traces
 	switch config.OtelProtocol() {
 	case OtelProtocolGRPC:
-		exporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(config.TracesURL()))
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpointURL(config.TracesURL())}
+		if len(exporterSettings.headers) > 0 {
+			options = append(options, otlptracegrpc.WithHeaders(exporterSettings.headers))
+		}
+		if exporterSettings.gzip() {
+			options = append(options, otlptracegrpc.WithCompressor(otelCompressionGzip))
+		}
+		if exporterSettings.tlsConfig != nil {
+			options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(exporterSettings.tlsConfig)))
+		}
+		exporter, err = otlptracegrpc.New(ctx, options...)
 	case OtelProtocolHTTP:
-		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.TracesURL()))
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(config.TracesURL())}
+		if len(exporterSettings.headers) > 0 {
+			options = append(options, otlptracehttp.WithHeaders(exporterSettings.headers))
+		}
+		if exporterSettings.compression != "" {
+			compression := otlptracehttp.NoCompression
+			if exporterSettings.gzip() {
+				compression = otlptracehttp.GzipCompression
+			}
+			options = append(options, otlptracehttp.WithCompression(compression))
+		}
+		if exporterSettings.tlsConfig != nil {
+			options = append(options, otlptracehttp.WithTLSClientConfig(exporterSettings.tlsConfig))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
 	default:
 		return nil, fmt.Errorf("unknown observability protocol %q", config.Protocol)
 	}
@@ -55,32 +88,58 @@ func newTracerProvider(ctx context.Context, config OtelConfig, serviceResource *
 		return nil, err
 	}
 
-	spanLimits := trace.SpanLimits{
-		AttributePerEventCountLimit: OtelSpanLimitAttributePerEvent,
-		EventCountLimit:             OtelSpanLimitEventCount,
+	batchOptions := []trace.BatchSpanProcessorOption{trace.WithBatchTimeout(settings.tracesExportInterval)}
+	if settings.tracesMaxQueueSize > 0 {
+		batchOptions = append(batchOptions, trace.WithMaxQueueSize(settings.tracesMaxQueueSize))
+	}
+	if settings.tracesMaxExportBatchSize > 0 {
+		batchOptions = append(batchOptions, trace.WithMaxExportBatchSize(settings.tracesMaxExportBatchSize))
 	}
 
 	traceProvider := trace.NewTracerProvider(
 		trace.WithResource(serviceResource),
-		trace.WithBatcher(exporter,
-			trace.WithBatchTimeout(OtelTraceExportInterval),
-		),
-		trace.WithRawSpanLimits(spanLimits),
-		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithBatcher(exporter, batchOptions...),
+		trace.WithRawSpanLimits(settings.spanLimits),
+		trace.WithSampler(settings.sampler),
 	)
 
 	return traceProvider, nil
 }
 
-func newMeterProvider(ctx context.Context, config OtelConfig, serviceResource *resource.Resource) (*metric.MeterProvider, error) {
+func newMeterProvider(ctx context.Context, config OtelConfig, settings otelSettings, serviceResource *resource.Resource) (*metric.MeterProvider, error) {
 	var exporter metric.Exporter
 	var err error
 
+	exporterSettings := settings.metrics
 	switch config.OtelProtocol() {
 	case OtelProtocolGRPC:
-		exporter, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(config.MetricsURL()))
+		options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpointURL(config.MetricsURL())}
+		if len(exporterSettings.headers) > 0 {
+			options = append(options, otlpmetricgrpc.WithHeaders(exporterSettings.headers))
+		}
+		if exporterSettings.gzip() {
+			options = append(options, otlpmetricgrpc.WithCompressor(otelCompressionGzip))
+		}
+		if exporterSettings.tlsConfig != nil {
+			options = append(options, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(exporterSettings.tlsConfig)))
+		}
+		exporter, err = otlpmetricgrpc.New(ctx, options...)
 	case OtelProtocolHTTP:
-		exporter, err = otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(config.MetricsURL()))
+		options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpointURL(config.MetricsURL())}
+		if len(exporterSettings.headers) > 0 {
+			options = append(options, otlpmetrichttp.WithHeaders(exporterSettings.headers))
+		}
+		if exporterSettings.compression != "" {
+			compression := otlpmetrichttp.NoCompression
+			if exporterSettings.gzip() {
+				compression = otlpmetrichttp.GzipCompression
+			}
+			options = append(options, otlpmetrichttp.WithCompression(compression))
+		}
+		if exporterSettings.tlsConfig != nil {
+			options = append(options, otlpmetrichttp.WithTLSClientConfig(exporterSettings.tlsConfig))
+		}
+		exporter, err = otlpmetrichttp.New(ctx, options...)
 	default:
 		return nil, fmt.Errorf("unknown observability protocol %q", config.Protocol)
 	}
@@ -92,21 +151,46 @@ func newMeterProvider(ctx context.Context, config OtelConfig, serviceResource *r
 	meterProvider := metric.NewMeterProvider(
 		metric.WithResource(serviceResource),
 		metric.WithReader(metric.NewPeriodicReader(exporter,
-			metric.WithInterval(OtelMetricExportInterval))),
+			metric.WithInterval(settings.metricsExportInterval))),
 	)
 
 	return meterProvider, nil
 }
 
-func newLoggerProvider(ctx context.Context, config OtelConfig, serviceResource *resource.Resource) (*log.LoggerProvider, error) {
+func newLoggerProvider(ctx context.Context, config OtelConfig, settings otelSettings, serviceResource *resource.Resource) (*log.LoggerProvider, error) {
 	var exporter log.Exporter
 	var err error
 
+	exporterSettings := settings.logs
 	switch config.OtelProtocol() {
 	case OtelProtocolGRPC:
-		exporter, err = otlploggrpc.New(ctx, otlploggrpc.WithEndpointURL(config.LogsURL()))
+		options := []otlploggrpc.Option{otlploggrpc.WithEndpointURL(config.LogsURL())}
+		if len(exporterSettings.headers) > 0 {
+			options = append(options, otlploggrpc.WithHeaders(exporterSettings.headers))
+		}
+		if exporterSettings.gzip() {
+			options = append(options, otlploggrpc.WithCompressor(otelCompressionGzip))
+		}
+		if exporterSettings.tlsConfig != nil {
+			options = append(options, otlploggrpc.WithTLSCredentials(credentials.NewTLS(exporterSettings.tlsConfig)))
+		}
+		exporter, err = otlploggrpc.New(ctx, options...)
 	case OtelProtocolHTTP:
-		exporter, err = otlploghttp.New(ctx, otlploghttp.WithEndpointURL(config.LogsURL()))
+		options := []otlploghttp.Option{otlploghttp.WithEndpointURL(config.LogsURL())}
+		if len(exporterSettings.headers) > 0 {
+			options = append(options, otlploghttp.WithHeaders(exporterSettings.headers))
+		}
+		if exporterSettings.compression != "" {
+			compression := otlploghttp.NoCompression
+			if exporterSettings.gzip() {
+				compression = otlploghttp.GzipCompression
+			}
+			options = append(options, otlploghttp.WithCompression(compression))
+		}
+		if exporterSettings.tlsConfig != nil {
+			options = append(options, otlploghttp.WithTLSClientConfig(exporterSettings.tlsConfig))
+		}
+		exporter, err = otlploghttp.New(ctx, options...)
 	default:
 		return nil, fmt.Errorf("unknown observability protocol %q", config.Protocol)
 	}
@@ -115,15 +199,371 @@ func newLoggerProvider(ctx context.Context, config OtelConfig, serviceResource *
 		return nil, err
 	}
 
+	batchOptions := []log.BatchProcessorOption{log.WithExportInterval(settings.logsExportInterval)}
+	if settings.logsMaxQueueSize > 0 {
+		batchOptions = append(batchOptions, log.WithMaxQueueSize(settings.logsMaxQueueSize))
+	}
+	if settings.logsMaxExportBatchSize > 0 {
+		batchOptions = append(batchOptions, log.WithExportMaxBatchSize(settings.logsMaxExportBatchSize))
+	}
+
 	loggerProvider := log.NewLoggerProvider(
 		log.WithResource(serviceResource),
-		log.WithProcessor(log.NewBatchProcessor(exporter,
-			log.WithExportInterval(OtelLogExportInterval))),
+		log.WithProcessor(log.NewBatchProcessor(exporter, batchOptions...)),
 	)
 
 	return loggerProvider, nil
 }
 
+const (
+	otelCompressionGzip = "gzip"
+	otelCompressionNone = "none"
+)
+
+// otelSettings holds the telemetry settings resolved from the OtelConfig and the OTEL_*
+// environment variables.
+type otelSettings struct {
+	sampler    trace.Sampler
+	spanLimits trace.SpanLimits
+
+	tracesExportInterval  time.Duration
+	metricsExportInterval time.Duration
+	logsExportInterval    time.Duration
+
+	// Zero values use the SDK defaults
+	tracesMaxQueueSize       int
+	tracesMaxExportBatchSize int
+	logsMaxQueueSize         int
+	logsMaxExportBatchSize   int
+
+	traces  otelExporterSettings
+	metrics otelExporterSettings
+	logs    otelExporterSettings
+}
+
+// otelExporterSettings holds the settings of the OTLP exporter of a signal. Zero values use
+// the exporter defaults.
+type otelExporterSettings struct {
+	headers     map[string]string
+	compression string
+	tlsConfig   *tls.Config
+}
+
+func (s otelExporterSettings) gzip() bool {
+	return s.compression == otelCompressionGzip
+}
+
+// otelEnv looks up OTEL_* environment variables in the values sent by the host, then in the
+// environment of the provider process.
+type otelEnv func(key string) (string, bool)
+
+func newOtelEnv(envValues map[string]string) otelEnv {
+	return func(key string) (string, bool) {
+		if value, ok := envValues[key]; ok {
+			return value, true
+		}
+		return os.LookupEnv(key)
+	}
+}
+
+// resolveOtelSettings resolves the telemetry settings. Values set in the OtelConfig take
+// precedence over the OTEL_* environment variables, which take precedence over the defaults.
+// See https://opentelemetry.io/docs/languages/sdk-configuration/
+//
+// Only the settings of the enabled signals are resolved, so that invalid settings of disabled
+// signals don't prevent the provider from starting.
+func resolveOtelSettings(config OtelConfig, env otelEnv) (otelSettings, error) {
+	var settings otelSettings
+	var err error
+	tracesEnabled, metricsEnabled, logsEnabled := config.TracesEnabled(), config.MetricsEnabled(), config.LogsEnabled()
+
+	if tracesEnabled {
+		samplerName := config.TracesSampler
+		if samplerName == "" {
+			samplerName, _ = env("OTEL_TRACES_SAMPLER")
+		}
+		samplerArg := config.TracesSamplerArg
+		if samplerArg == "" {
+			samplerArg, _ = env("OTEL_TRACES_SAMPLER_ARG")
+		}
+		settings.sampler, err = newSampler(samplerName, samplerArg)
+		if err != nil {
+			return settings, err
+		}
+	}
+
+	intervals := []struct {
+		enabled    bool
+		target     *time.Duration
+		configured *uint64
+		envKey     string
+		fallback   time.Duration
+	}{
+		{tracesEnabled, &settings.tracesExportInterval, config.TracesExportIntervalMS, "OTEL_BSP_SCHEDULE_DELAY", OtelTraceExportInterval},
+		{metricsEnabled, &settings.metricsExportInterval, config.MetricsExportIntervalMS, "OTEL_METRIC_EXPORT_INTERVAL", OtelMetricExportInterval},
+		{logsEnabled, &settings.logsExportInterval, config.LogsExportIntervalMS, "OTEL_BLRP_SCHEDULE_DELAY", OtelLogExportInterval},
+	}
+	for _, interval := range intervals {
+		if !interval.enabled {
+			continue
+		}
+		*interval.target = interval.fallback
+		if interval.configured != nil {
+			*interval.target = time.Duration(*interval.configured) * time.Millisecond
+			continue
+		}
+		ms, ok, err := envInt(env, interval.envKey)
+		if err != nil {
+			return settings, err
+		}
+		if ok {
+			*interval.target = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	sizes := []struct {
+		enabled    bool
+		target     *int
+		configured int
+		envKey     string
+	}{
+		{tracesEnabled, &settings.tracesMaxQueueSize, config.MaxBatchQueueSize, "OTEL_BSP_MAX_QUEUE_SIZE"},
+		{tracesEnabled, &settings.tracesMaxExportBatchSize, config.MaxExportBatchSize, "OTEL_BSP_MAX_EXPORT_BATCH_SIZE"},
+		{logsEnabled, &settings.logsMaxQueueSize, config.MaxBatchQueueSize, "OTEL_BLRP_MAX_QUEUE_SIZE"},
+		{logsEnabled, &settings.logsMaxExportBatchSize, config.MaxExportBatchSize, "OTEL_BLRP_MAX_EXPORT_BATCH_SIZE"},
+	}
+	for _, size := range sizes {
+		if !size.enabled {
+			continue
+		}
+		if err := resolveInt(size.target, size.configured, env, size.envKey, 0); err != nil {
+			return settings, err
+		}
+	}
+
+	limits := []struct {
+		target     *int
+		configured int
+		envKey     string
+		fallback   int
+	}{
+		{&settings.spanLimits.AttributeCountLimit, config.SpanLimits.AttributeCount, "OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", trace.DefaultAttributeCountLimit},
+		{&settings.spanLimits.AttributeValueLengthLimit, config.SpanLimits.AttributeValueLength, "OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT", trace.DefaultAttributeValueLengthLimit},
+		{&settings.spanLimits.EventCountLimit, config.SpanLimits.EventCount, "OTEL_SPAN_EVENT_COUNT_LIMIT", OtelSpanLimitEventCount},
+		{&settings.spanLimits.LinkCountLimit, config.SpanLimits.LinkCount, "OTEL_SPAN_LINK_COUNT_LIMIT", trace.DefaultLinkCountLimit},
+		{&settings.spanLimits.AttributePerEventCountLimit, config.SpanLimits.AttributePerEventCount, "OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT", OtelSpanLimitAttributePerEvent},
+		{&settings.spanLimits.AttributePerLinkCountLimit, config.SpanLimits.AttributePerLinkCount, "OTEL_LINK_ATTRIBUTE_COUNT_LIMIT", trace.DefaultAttributePerLinkCountLimit},
+	}
+	for _, limit := range limits {
+		if !tracesEnabled {
+			continue
+		}
+		if err := resolveInt(limit.target, limit.configured, env, limit.envKey, limit.fallback); err != nil {
+			return settings, err
+		}
+	}
+
+	for signal, exporter := range map[string]struct {
+		enabled bool
+		target  *otelExporterSettings
+	}{
+		"TRACES":  {tracesEnabled, &settings.traces},
+		"METRICS": {metricsEnabled, &settings.metrics},
+		"LOGS":    {logsEnabled, &settings.logs},
+	} {
+		if !exporter.enabled {
+			continue
+		}
+		*exporter.target, err = resolveOtelExporterSettings(config, env, signal)
+		if err != nil {
+			return settings, fmt.Errorf("invalid %s exporter settings: %w", strings.ToLower(signal), err)
+		}
+	}
+
+	return settings, nil
+}
+
+func resolveOtelExporterSettings(config OtelConfig, env otelEnv, signal string) (otelExporterSettings, error) {
+	var settings otelExporterSettings
+
+	// Signal specific variables take precedence over the generic ones
+	lookup := func(suffix string) (string, bool) {
+		if value, ok := env("OTEL_EXPORTER_OTLP_" + signal + "_" + suffix); ok {
+			return value, true
+		}
+		return env("OTEL_EXPORTER_OTLP_" + suffix)
+	}
+
+	if value, ok := lookup("HEADERS"); ok {
+		headers, err := parseOtelHeaders(value)
+		if err != nil {
+			return settings, err
+		}
+		settings.headers = headers
+	}
+	if len(config.Headers) > 0 {
+		if settings.headers == nil {
+			settings.headers = make(map[string]string, len(config.Headers))
+		}
+		for key, value := range config.Headers {
+			settings.headers[key] = value.Reveal()
+		}
+	}
+
+	settings.compression = config.Compression
+	if settings.compression == "" {
+		settings.compression, _ = lookup("COMPRESSION")
+	}
+	settings.compression = strings.ToLower(strings.TrimSpace(settings.compression))
+	if settings.compression != "" && settings.compression != otelCompressionGzip && settings.compression != otelCompressionNone {
+		return settings, fmt.Errorf("unsupported compression %q", settings.compression)
+	}
+
+	caPaths := slices.Clone(config.AdditionalCAPaths)
+	if path, ok := lookup("CERTIFICATE"); ok && path != "" {
+		caPaths = append(caPaths, path)
+	}
+	certPath := config.ClientCertificatePath
+	if certPath == "" {
+		certPath, _ = lookup("CLIENT_CERTIFICATE")
+	}
+	keyPath := config.ClientKeyPath
+	if keyPath == "" {
+		keyPath, _ = lookup("CLIENT_KEY")
+	}
+	tlsConfig, err := newOtelTLSConfig(caPaths, certPath, keyPath)
+	if err != nil {
+		return settings, err
+	}
+	settings.tlsConfig = tlsConfig
+
+	return settings, nil
+}
+
+// newOtelTLSConfig returns a TLS config trusting the system and the additional certificate
+// authorities, with an optional client certificate. It returns nil if nothing is configured.
+func newOtelTLSConfig(caPaths []string, certPath string, keyPath string) (*tls.Config, error) {
+	if len(caPaths) == 0 && certPath == "" && keyPath == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caPaths) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range caPaths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read certificate authority: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificate found in %s", path)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, errors.New("both a client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newSampler returns the sampler named like the OTEL_TRACES_SAMPLER values, always_on by default.
+func newSampler(name string, arg string) (trace.Sampler, error) {
+	ratio := 1.0
+	if arg != "" {
+		var err error
+		ratio, err = strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid sampler ratio %q, expected a number between 0 and 1", arg)
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "always_on":
+		return trace.AlwaysSample(), nil
+	case "always_off":
+		return trace.NeverSample(), nil
+	case "traceidratio":
+		return trace.TraceIDRatioBased(ratio), nil
+	case "parentbased_always_on":
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return trace.ParentBased(trace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return trace.ParentBased(trace.TraceIDRatioBased(ratio)), nil
+	default:
+		return nil, fmt.Errorf("unsupported sampler %q", name)
+	}
+}
+
+// parseOtelHeaders parses headers in the OTEL_EXPORTER_OTLP_HEADERS format: comma separated,
+// URL encoded key=value pairs.
+func parseOtelHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		key, err := url.PathUnescape(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header key %q: %w", key, err)
+		}
+		val, err = url.PathUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header value for %q: %w", key, err)
+		}
+		headers[key] = val
+	}
+	return headers, nil
+}
+
+// resolveInt sets target to configured if it isn't zero, otherwise to the integer in the
+// environment variable key if it is set, otherwise to fallback.
+func resolveInt(target *int, configured int, env otelEnv, key string, fallback int) error {
+	if configured != 0 {
+		*target = configured
+		return nil
+	}
+	value, ok, err := envInt(env, key)
+	if err != nil {
+		return err
+	}
+	if ok {
+		*target = value
+	} else {
+		*target = fallback
+	}
+	return nil
+}
+
+func envInt(env otelEnv, key string) (int, bool, error) {
+	raw, ok := env(key)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return 0, false, nil
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
 func newServiceResource(ctx context.Context, hostData HostData) (*resource.Resource, error) {
 	providerBinary, err := os.Executable()
 	if err != nil {
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveOtelSettingsDefaults(t *testing.T) {
+	settings, err := resolveOtelSettings(OtelConfig{EnableObservability: true}, newOtelEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.sampler.Description() != "AlwaysOnSampler" {
+		t.Errorf("unexpected default sampler: %s", settings.sampler.Description())
+	}
+	if settings.tracesExportInterval != OtelTraceExportInterval ||
+		settings.metricsExportInterval != OtelMetricExportInterval ||
+		settings.logsExportInterval != OtelLogExportInterval {
+		t.Errorf("unexpected default export intervals: %+v", settings)
+	}
+	limits := settings.spanLimits
+	if limits.AttributeCountLimit != 128 || limits.EventCountLimit != OtelSpanLimitEventCount ||
+		limits.AttributePerEventCountLimit != OtelSpanLimitAttributePerEvent || limits.AttributeValueLengthLimit != -1 {
+		t.Errorf("unexpected default span limits: %+v", limits)
+	}
+	if settings.traces.headers != nil || settings.traces.compression != "" || settings.traces.tlsConfig != nil {
+		t.Errorf("unexpected default exporter settings: %+v", settings.traces)
+	}
+}
+
+func TestResolveOtelSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeCertificate(t, dir, "ca", nil, nil)
+
+	interval := uint64(5000)
+	config := OtelConfig{
+		EnableObservability:     true,
+		TracesSampler:           "parentbased_traceidratio",
+		TracesSamplerArg:        "0.25",
+		MetricsExportIntervalMS: &interval,
+		SpanLimits:              OtelSpanLimits{AttributeCount: 32, LinkCount: -1},
+		Headers:                 map[string]RedactedString{"authorization": "Bearer config"},
+		AdditionalCAPaths:       []string{filepath.Join(dir, "ca.pem")},
+	}
+	env := map[string]string{
+		"OTEL_TRACES_SAMPLER":                    "always_off",
+		"OTEL_BSP_SCHEDULE_DELAY":                "2000",
+		"OTEL_METRIC_EXPORT_INTERVAL":            "1000",
+		"OTEL_BSP_MAX_QUEUE_SIZE":                "4096",
+		"OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT":        "64",
+		"OTEL_SPAN_EVENT_COUNT_LIMIT":            "10",
+		"OTEL_EXPORTER_OTLP_HEADERS":             "authorization=Bearer%20env,x-tenant=acme",
+		"OTEL_EXPORTER_OTLP_LOGS_HEADERS":        "x-tenant=logs",
+		"OTEL_EXPORTER_OTLP_COMPRESSION":         "gzip",
+		"OTEL_EXPORTER_OTLP_METRICS_COMPRESSION": "none",
+	}
+
+	settings, err := resolveOtelSettings(config, newOtelEnv(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The OtelConfig takes precedence over the environment
+	if !strings.Contains(settings.sampler.Description(), "TraceIDRatioBased{0.25}") || !strings.HasPrefix(settings.sampler.Description(), "ParentBased") {
+		t.Errorf("unexpected sampler: %s", settings.sampler.Description())
+	}
+	if settings.tracesExportInterval != 2*time.Second || settings.metricsExportInterval != 5*time.Second {
+		t.Errorf("unexpected export intervals: %v, %v", settings.tracesExportInterval, settings.metricsExportInterval)
+	}
+	if settings.tracesMaxQueueSize != 4096 || settings.logsMaxQueueSize != 0 {
+		t.Errorf("unexpected queue sizes: %d, %d", settings.tracesMaxQueueSize, settings.logsMaxQueueSize)
+	}
+	limits := settings.spanLimits
+	if limits.AttributeCountLimit != 32 || limits.EventCountLimit != 10 || limits.LinkCountLimit != -1 {
+		t.Errorf("unexpected span limits: %+v", limits)
+	}
+
+	if settings.traces.headers["authorization"] != "Bearer config" || settings.traces.headers["x-tenant"] != "acme" {
+		t.Errorf("unexpected traces headers: %v", settings.traces.headers)
+	}
+	if settings.logs.headers["x-tenant"] != "logs" {
+		t.Errorf("expected the logs specific headers, got %v", settings.logs.headers)
+	}
+	if !settings.traces.gzip() || settings.metrics.gzip() {
+		t.Errorf("unexpected compression: traces %q, metrics %q", settings.traces.compression, settings.metrics.compression)
+	}
+	if settings.traces.tlsConfig == nil || settings.traces.tlsConfig.RootCAs == nil {
+		t.Error("expected a TLS config trusting the additional certificate authority")
+	}
+}
+
+func TestResolveOtelSettingsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeCertificate(t, dir, "client", nil, nil)
+
+	tests := map[string]struct {
+		config OtelConfig
+		env    map[string]string
+	}{
+		"unknown sampler":     {config: OtelConfig{TracesSampler: "sometimes"}},
+		"invalid ratio":       {config: OtelConfig{TracesSampler: "traceidratio", TracesSamplerArg: "2"}},
+		"invalid interval":    {env: map[string]string{"OTEL_METRIC_EXPORT_INTERVAL": "1m"}},
+		"invalid headers":     {env: map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "novalue"}},
+		"invalid compression": {config: OtelConfig{Compression: "zstd"}},
+		"missing ca":          {config: OtelConfig{AdditionalCAPaths: []string{filepath.Join(dir, "missing.pem")}}},
+		"missing client key":  {config: OtelConfig{ClientCertificatePath: filepath.Join(dir, "client.pem")}},
+	}
+	for name, test := range tests {
+		test.config.EnableObservability = true
+		if _, err := resolveOtelSettings(test.config, newOtelEnv(test.env)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	config := OtelConfig{
+		EnableObservability:   true,
+		ClientCertificatePath: filepath.Join(dir, "client.pem"),
+		ClientKeyPath:         filepath.Join(dir, "client-key.pem"),
+	}
+	settings, err := resolveOtelSettings(config, newOtelEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.logs.tlsConfig.Certificates) != 1 {
+		t.Error("expected the client certificate to be loaded")
+	}
+}
+
+func TestResolveOtelSettingsDisabledSignals(t *testing.T) {
+	env := map[string]string{
+		"OTEL_TRACES_SAMPLER":                   "jaeger_remote",
+		"OTEL_BSP_MAX_QUEUE_SIZE":               "many",
+		"OTEL_EXPORTER_OTLP_TRACES_CERTIFICATE": "/missing/ca.pem",
+	}
+
+	// The settings of disabled signals are ignored
+	settings, err := resolveOtelSettings(OtelConfig{}, newOtelEnv(env))
+	if err != nil {
+		t.Fatalf("expected no error with telemetry disabled, got %v", err)
+	}
+	if settings.sampler != nil {
+		t.Errorf("expected no sampler, got %s", settings.sampler.Description())
+	}
+	if _, err := resolveOtelSettings(OtelConfig{EnableMetrics: true}, newOtelEnv(env)); err != nil {
+		t.Errorf("expected no error with only metrics enabled, got %v", err)
+	}
+	if _, err := resolveOtelSettings(OtelConfig{EnableTraces: true}, newOtelEnv(env)); err == nil {
+		t.Error("expected an error with traces enabled")
+	}
+}
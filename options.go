@@ -1,5 +1,14 @@
 package provider
 
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
 type ProviderHandler func(*WasmcloudProvider) error
 
 func SourceLinkPut(inFunc func(InterfaceLinkDefinition) error) ProviderHandler {
@@ -30,6 +39,26 @@ func TargetLinkDel(inFunc func(InterfaceLinkDefinition) error) ProviderHandler {
 	}
 }
 
+// SourceLinkUpdate registers a function called with the old and new definition when a link
+// where the provider is the source is put again with different config or secrets. If not set,
+// the SourceLinkDel function is called with the old link, then SourceLinkPut with the new one.
+func SourceLinkUpdate(inFunc func(old InterfaceLinkDefinition, new InterfaceLinkDefinition) error) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.updateSourceLinkFunc = inFunc
+		return nil
+	}
+}
+
+// TargetLinkUpdate registers a function called with the old and new definition when a link
+// where the provider is the target is put again with different config or secrets. If not set,
+// the TargetLinkDel function is called with the old link, then TargetLinkPut with the new one.
+func TargetLinkUpdate(inFunc func(old InterfaceLinkDefinition, new InterfaceLinkDefinition) error) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.updateTargetLinkFunc = inFunc
+		return nil
+	}
+}
+
 func Shutdown(inFunc func() error) ProviderHandler {
 	return func(wp *WasmcloudProvider) error {
 		wp.shutdownFunc = inFunc
@@ -37,9 +66,152 @@ func Shutdown(inFunc func() error) ProviderHandler {
 	}
 }
 
+// HealthCheck sets a function returning the message reported when the provider is healthy.
+// Use HealthCheckStatus to also be able to report the provider as unhealthy.
 func HealthCheck(inFunc func() string) ProviderHandler {
+	return HealthCheckStatus(func(context.Context) (HealthStatus, error) {
+		return HealthStatus{Healthy: true, Message: inFunc()}, nil
+	})
+}
+
+// HealthCheckStatus sets the function checking the health of the provider.
+func HealthCheckStatus(inFunc HealthCheckFunc) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.healthCheckFunc = inFunc
+		return nil
+	}
+}
+
+// NamedHealthCheck adds a health check that runs alongside the provider health check, ex: to
+// check a database connection. If timeout is zero, the HealthCheckTimeout is used.
+func NamedHealthCheck(name string, timeout time.Duration, inFunc HealthCheckFunc) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		for _, check := range wp.healthChecks {
+			if check.name == name {
+				return fmt.Errorf("health check %q is already registered", name)
+			}
+		}
+		wp.healthChecks = append(wp.healthChecks, namedHealthCheck{name: name, timeout: timeout, check: inFunc})
+		return nil
+	}
+}
+
+// HealthCheckTimeout sets the default time a health check has to complete before the
+// provider is reported as unhealthy.
+func HealthCheckTimeout(timeout time.Duration) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.healthCheckTimeout = timeout
+		return nil
+	}
+}
+
+// NatsOptions adds options used when connecting to the lattice, in addition to the
+// credentials provided by the host. Disconnect, reconnect and closed handlers are called after the
+// ones of the provider, see ConnectionDisconnected, ConnectionReconnected and ConnectionClosed.
+func NatsOptions(opts ...nats.Option) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.natsOptions = append(wp.natsOptions, opts...)
+		return nil
+	}
+}
+
+// TLSRootCAs configures the lattice connection to verify the server certificate
+// against the given PEM encoded CA files.
+func TLSRootCAs(files ...string) ProviderHandler {
+	return NatsOptions(nats.RootCAs(files...))
+}
+
+// TLSClientCert configures the lattice connection to present the given PEM encoded
+// client certificate and key.
+func TLSClientCert(certFile string, keyFile string) ProviderHandler {
+	return NatsOptions(nats.ClientCert(certFile, keyFile))
+}
+
+// TLSConfig configures the lattice connection with a custom TLS configuration.
+func TLSConfig(config *tls.Config) ProviderHandler {
+	return NatsOptions(nats.Secure(config))
+}
+
+// MaxReconnects sets the number of reconnect attempts to the lattice before the
+// connection is closed. A negative value reconnects forever.
+func MaxReconnects(max int) ProviderHandler {
+	return NatsOptions(nats.MaxReconnects(max))
+}
+
+// ReconnectWait sets the time to wait between reconnect attempts to the same server.
+func ReconnectWait(wait time.Duration) ProviderHandler {
+	return NatsOptions(nats.ReconnectWait(wait))
+}
+
+// ReconnectJitter sets the upper bound of the random delay added to ReconnectWait,
+// for plain and TLS connections respectively.
+func ReconnectJitter(jitter time.Duration, jitterTLS time.Duration) ProviderHandler {
+	return NatsOptions(nats.ReconnectJitter(jitter, jitterTLS))
+}
+
+// ReconnectBackoff sets a function returning the delay before the next reconnect attempt,
+// overriding ReconnectWait and ReconnectJitter. It can be used to implement exponential backoff.
+func ReconnectBackoff(inFunc func(attempts int) time.Duration) ProviderHandler {
+	return NatsOptions(nats.CustomReconnectDelay(inFunc))
+}
+
+// ConnectionDisconnected registers a function called when the lattice connection is lost.
+func ConnectionDisconnected(inFunc func(error)) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.disconnectedFunc = inFunc
+		return nil
+	}
+}
+
+// ConnectionReconnected registers a function called when the lattice connection is re-established.
+func ConnectionReconnected(inFunc func()) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.reconnectedFunc = inFunc
+		return nil
+	}
+}
+
+// ConnectionClosed registers a function called when the lattice connection is closed and
+// no further reconnects will be attempted.
+func ConnectionClosed(inFunc func()) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.connectionClosedFunc = inFunc
+		return nil
+	}
+}
+
+// ShutdownTimeout sets the time the whole graceful shutdown may take: waiting for in-flight
+// invocations, running the Shutdown function, flushing telemetry and draining the lattice
+// connection. Defaults to 10 seconds.
+func ShutdownTimeout(timeout time.Duration) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		if timeout <= 0 {
+			return fmt.Errorf("shutdown timeout must be positive, got %s", timeout)
+		}
+		wp.shutdownTimeout = timeout
+		return nil
+	}
+}
+
+// SecretsChanged registers a function called when a link is put again with rotated secrets
+// on the provider's side, after the link update handlers and once Secret returns the new
+// values. Can be used multiple times to register several functions.
+func SecretsChanged(inFunc func(SecretsChange) error) ProviderHandler {
+	return func(wp *WasmcloudProvider) error {
+		wp.secretsChangedFuncs = append(wp.secretsChangedFuncs, inFunc)
+		return nil
+	}
+}
+
+// ProviderConfig decodes the provider configuration into cfg when the provider is created,
+// failing provider creation if it is invalid. See DecodeProviderConfig.
+func ProviderConfig[T any](cfg *T) ProviderHandler {
 	return func(wp *WasmcloudProvider) error {
-		wp.healthMsgFunc = inFunc
+		decoded, err := DecodeProviderConfig[T](wp.hostData)
+		if err != nil {
+			return err
+		}
+		*cfg = decoded
 		return nil
 	}
 }
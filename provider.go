@@ -1,37 +1,33 @@
 package provider
 
 import (
-	"bufio"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
+	"maps"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	nats "github.com/nats-io/nats.go"
 	"github.com/nats-io/nkeys"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/log/global"
+	wrpc "wrpc.io/go"
 	wrpcnats "wrpc.io/go/nats"
 )
 
-const (
-	hostDataReadTimeout = 5 * time.Second
-)
-
 type WasmcloudProvider struct {
 	Id string
 
 	context context.Context
 	cancel  context.CancelFunc
 	Logger  *slog.Logger
+	// logLevel is the level of Logger, see SetLogLevel
+	logLevel *slog.LevelVar
 
 	hostData     HostData
 	hostXkey     nkeys.KeyPair
@@ -39,67 +35,82 @@ type WasmcloudProvider struct {
 
 	Topics Topics
 
+	// Deprecated: exports served with RPCClient directly are neither traced nor stopped on
+	// shutdown. Serve them with RPCServer, and invoke components with OutgoingInvoker.
 	RPCClient *wrpcnats.Client
 
 	natsConnection    *nats.Conn
 	natsSubscriptions map[string]*nats.Subscription
+	// natsOptions holds additional options (ex: TLS) used when connecting to the lattice.
+	natsOptions []nats.Option
+	// linksWatcher reloads the links file in dev mode, see NewDev
+	linksWatcher *devLinksWatcher
+
+	healthCheckFunc    HealthCheckFunc
+	healthChecks       []namedHealthCheck
+	healthCheckTimeout time.Duration
 
-	healthMsgFunc func() string
+	disconnectedFunc     func(error)
+	reconnectedFunc      func()
+	connectionClosedFunc func()
+
+	// rpcServer serves the provider exports, see RPCServer
+	rpcServer *rpcServer
+	metrics   *providerMetrics
 
 	shutdownFunc func() error
 	// internalShutdownFuncs holds a list of callbacks triggered during shutdown (ex: opentelemetry exporter graceful shutdown).
-	// They are called after the user provided `shutdownFunc` and nats disconnect.
+	// They are called after the user provided `shutdownFunc` and before the lattice connection is closed.
 	internalShutdownFuncs []func(context.Context) error
-	shutdown              chan struct{}
+	shutdownTimeout       time.Duration
+	shutdownOnce          sync.Once
+	shutdownErr           error
+	shutdownReason        atomic.Value
+	// connectionClosed is closed once the lattice connection is closed
+	connectionClosed chan struct{}
 
 	putSourceLinkFunc func(InterfaceLinkDefinition) error
 	putTargetLinkFunc func(InterfaceLinkDefinition) error
 	delSourceLinkFunc func(InterfaceLinkDefinition) error
 	delTargetLinkFunc func(InterfaceLinkDefinition) error
-
-	lock sync.Mutex
+	// Called with the old and new definition when an existing link is put with different
+	// content. When nil, the link is deleted and put again instead.
+	updateSourceLinkFunc func(InterfaceLinkDefinition, InterfaceLinkDefinition) error
+	updateTargetLinkFunc func(InterfaceLinkDefinition, InterfaceLinkDefinition) error
+	// Called when a link is put again with rotated secrets on the provider's side
+	secretsChangedFuncs []func(SecretsChange) error
+
+	// linkLock serializes link puts and deletes, so their handlers can be called without
+	// holding lock and look up links themselves
+	linkLock sync.Mutex
+	lock     sync.Mutex
+	// Provider secrets, from the host data
+	secrets map[string]SecretValue
 	// Links from the provider to other components, aka where the provider is the
-	// source of the link. Indexed by the component ID of the target
-	sourceLinks map[string]InterfaceLinkDefinition
+	// source of the link. Indexed by the component ID of the target, link name and WIT package
+	sourceLinks map[linkKey]InterfaceLinkDefinition
 	// Links from other components to the provider, aka where the provider is the
-	// target of the link. Indexed by the component ID of the source
-	targetLinks map[string]InterfaceLinkDefinition
+	// target of the link. Indexed by the component ID of the source, link name and WIT package
+	targetLinks map[linkKey]InterfaceLinkDefinition
 }
 
 func New(options ...ProviderHandler) (*WasmcloudProvider, error) {
 	return NewWithHostDataSource(os.Stdin, options...)
 }
 
+// NewWithHostDataSource reads the host data from source, waiting up to DefaultHostDataTimeout,
+// and creates a provider from it. Use ReadHostData and NewWithHostData to wait longer.
 func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*WasmcloudProvider, error) {
-	reader := bufio.NewReader(source)
-
-	// Make a channel to receive the host data so we can timeout if we don't receive it
-	// All host data is sent immediately after the provider starts
-	hostDataChannel := make(chan string, 1)
-	go func() {
-		hostDataRaw, err := reader.ReadString('\n')
-		if err != nil && err != io.EOF {
-			log.Fatal(err)
-		}
-		hostDataChannel <- hostDataRaw
-	}()
-
-	hostData := HostData{}
-	select {
-	case hostDataRaw := <-hostDataChannel:
-		decodedData, err := base64.StdEncoding.DecodeString(hostDataRaw)
-		if err != nil {
-			return nil, err
-		}
-
-		err = json.Unmarshal(decodedData, &hostData)
-		if err != nil {
-			return nil, err
-		}
-	case <-time.After(hostDataReadTimeout):
-		log.Fatal("failed to read host data, did not receive after 5 seconds")
+	hostData, err := ReadHostData(source, DefaultHostDataTimeout)
+	if err != nil {
+		return nil, err
 	}
+	return NewWithHostData(hostData, options...)
+}
 
+// NewWithHostData creates a provider from host data obtained elsewhere, for example with
+// HostDataFromFile or HostDataFromEnv when running outside of a host.
+func NewWithHostData(hostData HostData, options ...ProviderHandler) (*WasmcloudProvider, error) {
 	// Initialize Logging
 	var logger *slog.Logger
 	var level Level
@@ -108,13 +119,29 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 	} else {
 		level = Info
 	}
+	// A LevelVar allows changing the level at runtime, see SetLogLevel
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level.Level())
+	handlerOptions := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: replaceLevelName}
 	if hostData.StructuredLogging {
-		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		logger = slog.New(traceContextHandler{slog.NewJSONHandler(os.Stderr, handlerOptions)})
 	} else {
-		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		logger = slog.New(traceContextHandler{slog.NewTextHandler(os.Stderr, handlerOptions)})
 	}
 
 	var internalShutdownFuncs []func(context.Context) error
+	// Shut down the telemetry already set up if the provider fails to be created
+	created := false
+	defer func() {
+		if created {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+		defer cancel()
+		for _, shutdownFunc := range internalShutdownFuncs {
+			_ = shutdownFunc(ctx)
+		}
+	}()
 
 	// Initialize Observability
 	propagator := newPropagator()
@@ -125,8 +152,13 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 		return nil, err
 	}
 
+	otelSettings, err := resolveOtelSettings(hostData.OtelConfig, newOtelEnv(hostData.EnvValues))
+	if err != nil {
+		return nil, err
+	}
+
 	if hostData.OtelConfig.MetricsEnabled() {
-		meterProvider, err := newMeterProvider(context.Background(), hostData.OtelConfig, serviceResource)
+		meterProvider, err := newMeterProvider(context.Background(), hostData.OtelConfig, otelSettings, serviceResource)
 		if err != nil {
 			return nil, err
 		}
@@ -135,7 +167,7 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 	}
 
 	if hostData.OtelConfig.TracesEnabled() {
-		tracerProvider, err := newTracerProvider(context.Background(), hostData.OtelConfig, serviceResource)
+		tracerProvider, err := newTracerProvider(context.Background(), hostData.OtelConfig, otelSettings, serviceResource)
 		if err != nil {
 			return nil, err
 		}
@@ -144,18 +176,16 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 	}
 
 	if hostData.OtelConfig.LogsEnabled() {
-		loggerProvider, err := newLoggerProvider(context.Background(), hostData.OtelConfig, serviceResource)
+		loggerProvider, err := newLoggerProvider(context.Background(), hostData.OtelConfig, otelSettings, serviceResource)
 		if err != nil {
 			return nil, err
 		}
 		global.SetLoggerProvider(loggerProvider)
 		internalShutdownFuncs = append(internalShutdownFuncs, func(c context.Context) error { return loggerProvider.Shutdown(c) })
-	}
 
-	// Connect to NATS
-	nc, err := nats.Connect(hostData.LatticeRPCURL)
-	if err != nil {
-		return nil, err
+		// Send the provider logs to the collector as well as stderr
+		otelHandler := newOtelLogHandler(loggerProvider.Logger(instrumentationName), logLevel)
+		logger = slog.New(fanoutHandler{logger.Handler(), otelHandler})
 	}
 
 	logger.Debug("host config", "config", hostData)
@@ -207,42 +237,45 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 		}
 	}
 
-	prefix := fmt.Sprintf("%s.%s", hostData.LatticeRPCPrefix, hostData.ProviderKey)
-	wrpc := wrpcnats.NewClient(nc, wrpcnats.WithPrefix(prefix), wrpcnats.WithGroup(prefix))
-
-	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT)
-
-	ctx, cancel := context.WithCancel(context.Background())
+	// Outside of invocations, log with the provider logger, see LoggerFromContext
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), loggerKey{}, logger))
 	provider := &WasmcloudProvider{
-		Id:        hostData.ProviderKey,
-		Logger:    logger,
-		RPCClient: wrpc,
-		Topics:    LatticeTopics(hostData, providerXkey),
+		Id:       hostData.ProviderKey,
+		Logger:   logger,
+		logLevel: logLevel,
+		Topics:   LatticeTopics(hostData, providerXkey),
 
 		context: ctx,
 		cancel:  cancel,
 
 		hostData:     hostData,
+		secrets:      maps.Clone(hostData.Secrets),
 		hostXkey:     hostXkey,
 		providerXkey: providerXkey,
 
-		natsConnection:    nc,
 		natsSubscriptions: map[string]*nats.Subscription{},
 
-		healthMsgFunc: func() string { return "healthy" },
+		healthCheckFunc: func(context.Context) (HealthStatus, error) {
+			return HealthStatus{Healthy: true, Message: "healthy"}, nil
+		},
+		healthCheckTimeout: defaultHealthCheckTimeout,
+
+		disconnectedFunc:     func(error) {},
+		reconnectedFunc:      func() {},
+		connectionClosedFunc: func() {},
 
 		shutdownFunc:          func() error { return nil },
 		internalShutdownFuncs: internalShutdownFuncs,
-		shutdown:              make(chan struct{}),
+		shutdownTimeout:       defaultShutdownTimeout,
+		connectionClosed:      make(chan struct{}),
 
 		putSourceLinkFunc: func(InterfaceLinkDefinition) error { return nil },
 		putTargetLinkFunc: func(InterfaceLinkDefinition) error { return nil },
 		delSourceLinkFunc: func(InterfaceLinkDefinition) error { return nil },
 		delTargetLinkFunc: func(InterfaceLinkDefinition) error { return nil },
 
-		sourceLinks: make(map[string]InterfaceLinkDefinition, len(sourceLinks)),
-		targetLinks: make(map[string]InterfaceLinkDefinition, len(targetLinks)),
+		sourceLinks: make(map[linkKey]InterfaceLinkDefinition, len(sourceLinks)),
+		targetLinks: make(map[linkKey]InterfaceLinkDefinition, len(targetLinks)),
 	}
 
 	for _, opt := range options {
@@ -252,6 +285,27 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 		}
 	}
 
+	// Connect to NATS
+	authOptions, err := natsAuthOptions(hostData)
+	if err != nil {
+		return nil, err
+	}
+	natsOptions := append(authOptions, provider.natsOptions...)
+	natsOptions = append(natsOptions, provider.natsConnectionHandlers(provider.natsOptions)...)
+	nc, err := nats.Connect(hostData.LatticeRPCURL, natsOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("%s.%s", hostData.LatticeRPCPrefix, hostData.ProviderKey)
+	provider.natsConnection = nc
+	client := wrpcnats.NewClient(nc, wrpcnats.WithPrefix(prefix), wrpcnats.WithGroup(prefix))
+	provider.RPCClient = client
+	provider.rpcServer = newRPCServer(InstrumentServer(client), logger)
+	provider.metrics = newProviderMetrics(otel.GetMeterProvider(), provider)
+	// Stop observing the provider before the exporters are shut down
+	provider.internalShutdownFuncs = append([]func(context.Context) error{provider.metrics.unregister}, provider.internalShutdownFuncs...)
+
 	for _, link := range sourceLinks {
 		decryptedLink, err := provider.DecryptLinkSecrets(link)
 		if err != nil {
@@ -273,6 +327,7 @@ func NewWithHostDataSource(source io.Reader, options ...ProviderHandler) (*Wasmc
 			logger.Error("failed to update provider link map", slog.Any("error", err))
 		}
 	}
+	created = true
 	return provider, nil
 }
 
@@ -284,68 +339,80 @@ func (wp *WasmcloudProvider) NatsConnection() *nats.Conn {
 	return wp.natsConnection
 }
 
-func (wp *WasmcloudProvider) OutgoingRpcClient(target string) *wrpcnats.Client {
-	return wrpcnats.NewClient(wp.natsConnection, wrpcnats.WithPrefix(fmt.Sprintf("%s.%s", wp.hostData.LatticeRPCPrefix, target)))
+// Links returns a copy of all the links the provider is currently part of, either as the
+// source or the target.
+func (wp *WasmcloudProvider) Links() []InterfaceLinkDefinition {
+	return append(wp.SourceLinks(), wp.TargetLinks()...)
 }
 
-func (wp *WasmcloudProvider) Start() error {
-	for _, link := range wp.sourceLinks {
-		err := wp.putSourceLinkFunc(link)
-		if err != nil {
-			wp.Logger.Error("failed to invoke source link function", slog.Any("error", err))
-		}
-	}
-	for _, link := range wp.targetLinks {
-		err := wp.putTargetLinkFunc(link)
-		if err != nil {
-			wp.Logger.Error("failed to invoke target link function", slog.Any("error", err))
-		}
-	}
+// SourceLinks returns a copy of the links where the provider is the source.
+func (wp *WasmcloudProvider) SourceLinks() []InterfaceLinkDefinition {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return sortedLinks(wp.sourceLinks)
+}
 
-	err := wp.subToNats()
-	if err != nil {
-		return err
-	}
+// TargetLinks returns a copy of the links where the provider is the target.
+func (wp *WasmcloudProvider) TargetLinks() []InterfaceLinkDefinition {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return sortedLinks(wp.targetLinks)
+}
 
-	wp.Logger.Info("provider started", "id", wp.Id)
-	<-wp.context.Done()
-	wp.Logger.Info("provider exiting", "id", wp.Id)
-	return nil
+// LinkFor returns the link named name from the component sourceID to the provider. If the
+// component has multiple links with that name to different WIT packages, the first one
+// (ordered by WIT namespace and package) is returned.
+func (wp *WasmcloudProvider) LinkFor(sourceID string, name string) (InterfaceLinkDefinition, bool) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return findLink(wp.targetLinks, sourceID, name)
 }
 
-func (wp *WasmcloudProvider) Shutdown() error {
-	err := wp.shutdownFunc()
-	if err != nil {
-		wp.cancel()
-		return err
-	}
+// SourceLinkFor returns the link named name from the provider to the component target. If
+// the provider has multiple links with that name to different WIT packages, the first one
+// (ordered by WIT namespace and package) is returned.
+func (wp *WasmcloudProvider) SourceLinkFor(target string, name string) (InterfaceLinkDefinition, bool) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return findLink(wp.sourceLinks, target, name)
+}
 
-	err = wp.cleanupNatsSubscriptions()
-	if err != nil {
-		wp.cancel()
-		return err
-	}
+// RPCServer returns the server to serve the provider exports with, ex: with the Serve
+// function of generated bindings. Unlike serving them with RPCClient directly, exports
+// served with it are traced and measured, see InstrumentServer, and are stopped and their
+// in-flight invocations waited for on shutdown.
+func (wp *WasmcloudProvider) RPCServer() wrpc.Server {
+	return wp.rpcServer
+}
 
-	for _, errFunc := range wp.internalShutdownFuncs {
-		if err := errFunc(wp.context); err != nil {
-			wp.cancel()
-			return err
-		}
-	}
+// OutgoingRpcClient returns a wRPC client for the exports of target.
+//
+// Deprecated: invocations made with the returned client aren't traced nor measured, use
+// OutgoingInvoker instead.
+func (wp *WasmcloudProvider) OutgoingRpcClient(target string) *wrpcnats.Client {
+	return wrpcnats.NewClient(wp.natsConnection, wrpcnats.WithPrefix(fmt.Sprintf("%s.%s", wp.hostData.LatticeRPCPrefix, target)))
+}
 
-	wp.cancel()
-	return nil
+// OutgoingInvoker returns an invoker for the exports of target, with its invocations traced
+// and measured, see InstrumentInvoker.
+func (wp *WasmcloudProvider) OutgoingInvoker(target string) wrpc.Invoker {
+	return InstrumentInvoker(wp.OutgoingRpcClient(target))
+}
+
+// Shutdown gracefully shuts the provider down, see ShutdownTimeout. It is safe to call
+// multiple times, or after the host requested a shutdown.
+//
+// If the function registered with the Shutdown option is still running once the shutdown
+// timeout expires, the shutdown goes on without it, and it's left running in the background.
+func (wp *WasmcloudProvider) Shutdown() error {
+	return wp.shutdown(ExitReasonShutdown, nil)
 }
 
 func (wp *WasmcloudProvider) subToNats() error {
 	// ------------------ Subscribe to Health topic --------------------
 	health, err := wp.natsConnection.Subscribe(wp.Topics.LATTICE_HEALTH,
 		func(m *nats.Msg) {
-			msg := wp.healthMsgFunc()
-			hc := HealthCheckResponse{
-				Healthy: true,
-				Message: msg,
-			}
+			hc := wp.healthCheck(wp.context)
 
 			hcBytes, err := json.Marshal(hc)
 			if err != nil {
@@ -365,6 +432,32 @@ func (wp *WasmcloudProvider) subToNats() error {
 
 	wp.natsSubscriptions[wp.Topics.LATTICE_HEALTH] = health
 
+	// ------------------ Subscribe to Get links topic --------------
+	linkGet, err := wp.natsConnection.Subscribe(wp.Topics.LATTICE_LINK_GET,
+		func(m *nats.Msg) {
+			links := wp.Links()
+			for i, link := range links {
+				links[i] = link.redacted()
+			}
+
+			linksBytes, err := json.Marshal(links)
+			if err != nil {
+				wp.Logger.Error("failed to encode links", slog.Any("error", err))
+				return
+			}
+
+			err = m.Respond(linksBytes)
+			if err != nil {
+				wp.Logger.Error("failed to publish links response", slog.Any("error", err))
+			}
+		})
+	if err != nil {
+		wp.Logger.Error("LINK_GET", slog.Any("error", err))
+		return err
+	}
+
+	wp.natsSubscriptions[wp.Topics.LATTICE_LINK_GET] = linkGet
+
 	// ------------------ Subscribe to Delete link topic --------------
 	linkDel, err := wp.natsConnection.Subscribe(wp.Topics.LATTICE_LINK_DEL,
 		func(m *nats.Msg) {
@@ -372,6 +465,7 @@ func (wp *WasmcloudProvider) subToNats() error {
 			err := json.Unmarshal(m.Data, &link)
 			if err != nil {
 				wp.Logger.Error("failed to decode link", slog.Any("error", err))
+				wp.respondLink(m, err)
 				return
 			}
 
@@ -379,8 +473,8 @@ func (wp *WasmcloudProvider) subToNats() error {
 			if err != nil {
 				// TODO(#10): handle better?
 				wp.Logger.Error("failed to delete link", slog.Any("error", err))
-				return
 			}
+			wp.respondLink(m, err)
 		})
 	if err != nil {
 		wp.Logger.Error("LINK_DEL", slog.Any("error", err))
@@ -396,12 +490,14 @@ func (wp *WasmcloudProvider) subToNats() error {
 			err := json.Unmarshal(m.Data, &link)
 			if err != nil {
 				wp.Logger.Error("failed to decode link", slog.Any("error", err))
+				wp.respondLink(m, err)
 				return
 			}
 
 			providerLink, err := wp.DecryptLinkSecrets(link)
 			if err != nil {
 				wp.Logger.Error("failed to decrypt secrets on link", slog.Any("error", err))
+				wp.respondLink(m, err)
 				return
 			}
 
@@ -410,6 +506,7 @@ func (wp *WasmcloudProvider) subToNats() error {
 				// TODO(#10): handle this better?
 				wp.Logger.Error("newLinkFunc", slog.Any("error", err))
 			}
+			wp.respondLink(m, err)
 		})
 	if err != nil {
 		wp.Logger.Error("LINK_PUT", slog.Any("error", err))
@@ -421,24 +518,17 @@ func (wp *WasmcloudProvider) subToNats() error {
 	// ------------------ Subscribe to Shutdown topic ------------------
 	shutdown, err := wp.natsConnection.Subscribe(wp.Topics.LATTICE_SHUTDOWN,
 		func(m *nats.Msg) {
-			err := wp.shutdownFunc()
-			if err != nil {
-				// TODO(#10): handle this better?
-				wp.Logger.Error("ERROR: provider shutdown function failed: " + err.Error())
-			}
-
-			err = m.Respond([]byte("provider shutdown handled successfully"))
-			if err != nil {
-				// NOTE: This is a log message because we don't want to stop the shutdown process
-				wp.Logger.Error("ERROR: provider shutdown failed to respond: " + err.Error())
-			}
-
-			err = wp.cleanupNatsSubscriptions()
-			if err != nil {
-				wp.Logger.Error("ERROR: provider shutdown failed to drain connection: " + err.Error())
-			}
-
-			wp.cancel()
+			// Shut down in the background, as draining the connection waits for this
+			// handler to return
+			go func() {
+				_ = wp.shutdown(ExitReasonHostShutdown, func() {
+					err := m.Respond([]byte("provider shutdown handled successfully"))
+					if err != nil {
+						// NOTE: This is a log message because we don't want to stop the shutdown process
+						wp.Logger.Error("ERROR: provider shutdown failed to respond: " + err.Error())
+					}
+				})
+			}()
 		})
 	if err != nil {
 		wp.Logger.Error("LATTICE_SHUTDOWN", slog.Any("error", err))
@@ -449,6 +539,26 @@ func (wp *WasmcloudProvider) subToNats() error {
 	return nil
 }
 
+// respondLink acknowledges a link put or delete if it was sent as a request.
+func (wp *WasmcloudProvider) respondLink(m *nats.Msg, err error) {
+	if m.Reply == "" {
+		return
+	}
+
+	resp := linkResponse{Success: err == nil}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		wp.Logger.Error("failed to encode link response", slog.Any("error", err))
+		return
+	}
+	if err := m.Respond(respBytes); err != nil {
+		wp.Logger.Error("failed to publish link response", slog.Any("error", err))
+	}
+}
+
 func (wp *WasmcloudProvider) cleanupNatsSubscriptions() error {
 	err := wp.natsConnection.Flush()
 	if err != nil {
@@ -492,46 +602,140 @@ func (wp *WasmcloudProvider) DecryptLinkSecrets(h linkWithEncryptedSecrets) (Int
 }
 
 func (wp *WasmcloudProvider) putLink(l InterfaceLinkDefinition) error {
-	// Ignore duplicate links
-	if wp.isLinked(l.SourceID, l.Target) {
-		wp.Logger.Info("ignoring duplicate link", "link", l)
-		return nil
-	}
+	wp.linkLock.Lock()
+	defer wp.linkLock.Unlock()
+	change, err := wp.storeLink(l)
+	wp.metrics.recordLinkEvent(wp.context, wp.linkDirection(l), linkOperationPut, err)
+	if change != nil {
+		wp.refreshSecrets(*change)
+		wp.notifySecretsChanged(*change)
+	}
+	return err
+}
 
-	wp.lock.Lock()
-	defer wp.lock.Unlock()
+// storeLink calls the put or update handlers for a link and stores it. If the secrets of an
+// existing link changed, the change is returned so subscribers can be notified. The handlers
+// are called without holding the lock, the caller must hold linkLock.
+func (wp *WasmcloudProvider) storeLink(l InterfaceLinkDefinition) (*SecretsChange, error) {
 	if l.SourceID == wp.Id {
+		key := sourceLinkKey(l)
+		if existing, exists := wp.getLink(wp.sourceLinks, key); exists {
+			// Ignore duplicate links
+			if existing.equal(l) {
+				wp.Logger.Info("ignoring duplicate link", "link", l.redacted())
+				return nil, nil
+			}
+
+			err := wp.updateLink(wp.sourceLinks, key, existing, l, wp.updateSourceLinkFunc, wp.delSourceLinkFunc, wp.putSourceLinkFunc)
+			if err != nil {
+				return nil, err
+			}
+			return diffSecrets(l, existing.SourceSecrets, l.SourceSecrets), nil
+		}
+
 		err := wp.putSourceLinkFunc(l)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		wp.sourceLinks[l.Target] = l
+		wp.setLink(wp.sourceLinks, key, l)
 	} else if l.Target == wp.Id {
+		key := targetLinkKey(l)
+		if existing, exists := wp.getLink(wp.targetLinks, key); exists {
+			// Ignore duplicate links
+			if existing.equal(l) {
+				wp.Logger.Info("ignoring duplicate link", "link", l.redacted())
+				return nil, nil
+			}
+
+			err := wp.updateLink(wp.targetLinks, key, existing, l, wp.updateTargetLinkFunc, wp.delTargetLinkFunc, wp.putTargetLinkFunc)
+			if err != nil {
+				return nil, err
+			}
+			return diffSecrets(l, existing.TargetSecrets, l.TargetSecrets), nil
+		}
+
 		err := wp.putTargetLinkFunc(l)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		wp.targetLinks[l.SourceID] = l
+		wp.setLink(wp.targetLinks, key, l)
 	} else {
-		wp.Logger.Info("received link that isn't for this provider, ignoring", "link", l)
+		wp.Logger.Info("received link that isn't for this provider, ignoring", "link", l.redacted())
 	}
+	return nil, nil
+}
+
+// updateLink handles an existing link being put again with different content, ex: new config
+// or rotated secrets. If no update function is registered, the old link is deleted and the
+// updated one put instead. The caller must hold linkLock.
+func (wp *WasmcloudProvider) updateLink(
+	links map[linkKey]InterfaceLinkDefinition,
+	key linkKey,
+	old InterfaceLinkDefinition,
+	updated InterfaceLinkDefinition,
+	updateFunc func(InterfaceLinkDefinition, InterfaceLinkDefinition) error,
+	delFunc func(InterfaceLinkDefinition) error,
+	putFunc func(InterfaceLinkDefinition) error,
+) error {
+	wp.Logger.Info("updating link", "link", updated.redacted())
+	if updateFunc != nil {
+		err := updateFunc(old, updated)
+		if err != nil {
+			return err
+		}
+
+		wp.setLink(links, key, updated)
+		return nil
+	}
+
+	err := delFunc(old)
+	if err != nil {
+		return err
+	}
+	wp.forgetLink(links, key)
+
+	err = putFunc(updated)
+	if err != nil {
+		return err
+	}
+
+	wp.setLink(links, key, updated)
 	return nil
 }
 
+func (wp *WasmcloudProvider) getLink(links map[linkKey]InterfaceLinkDefinition, key linkKey) (InterfaceLinkDefinition, bool) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	l, exists := links[key]
+	return l, exists
+}
+
+func (wp *WasmcloudProvider) setLink(links map[linkKey]InterfaceLinkDefinition, key linkKey, l InterfaceLinkDefinition) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	links[key] = l
+}
+
+func (wp *WasmcloudProvider) forgetLink(links map[linkKey]InterfaceLinkDefinition, key linkKey) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	delete(links, key)
+}
+
 func (wp *WasmcloudProvider) updateProviderLinkMap(l InterfaceLinkDefinition) error {
 	// Ignore duplicate links
-	if wp.isLinked(l.SourceID, l.Target) {
+	if wp.isLinked(l) {
 		wp.Logger.Info("ignoring duplicate link", "link", l)
 		return nil
 	}
 	wp.lock.Lock()
 	defer wp.lock.Unlock()
 	if l.SourceID == wp.Id {
-		wp.sourceLinks[l.Target] = l
+		wp.sourceLinks[sourceLinkKey(l)] = l
 	} else if l.Target == wp.Id {
-		wp.targetLinks[l.SourceID] = l
+		wp.targetLinks[targetLinkKey(l)] = l
 	} else {
 		wp.Logger.Info("received link that isn't for this provider, ignoring", "link", l)
 	}
@@ -539,22 +743,30 @@ func (wp *WasmcloudProvider) updateProviderLinkMap(l InterfaceLinkDefinition) er
 }
 
 func (wp *WasmcloudProvider) deleteLink(l InterfaceLinkDefinition) error {
-	wp.lock.Lock()
-	defer wp.lock.Unlock()
+	wp.linkLock.Lock()
+	defer wp.linkLock.Unlock()
+	err := wp.removeLink(l)
+	wp.metrics.recordLinkEvent(wp.context, wp.linkDirection(l), linkOperationDelete, err)
+	return err
+}
+
+// removeLink calls the delete handlers for a link and forgets it. The handlers are called
+// without holding the lock, the caller must hold linkLock.
+func (wp *WasmcloudProvider) removeLink(l InterfaceLinkDefinition) error {
 	if l.SourceID == wp.Id {
 		err := wp.delSourceLinkFunc(l)
 		if err != nil {
 			return err
 		}
 
-		delete(wp.sourceLinks, l.Target)
+		wp.forgetLink(wp.sourceLinks, sourceLinkKey(l))
 	} else if l.Target == wp.Id {
 		err := wp.delTargetLinkFunc(l)
 		if err != nil {
 			return err
 		}
 
-		delete(wp.targetLinks, l.SourceID)
+		wp.forgetLink(wp.targetLinks, targetLinkKey(l))
 	} else {
 		wp.Logger.Info("received link delete that isn't for this provider, ignoring", "link", l)
 	}
@@ -562,14 +774,25 @@ func (wp *WasmcloudProvider) deleteLink(l InterfaceLinkDefinition) error {
 	return nil
 }
 
-func (wp *WasmcloudProvider) isLinked(sourceId string, target string) bool {
+// linkDirection returns whether the provider is the source or the target of a link, or an
+// empty string if the link isn't for this provider.
+func (wp *WasmcloudProvider) linkDirection(l InterfaceLinkDefinition) string {
+	if l.SourceID == wp.Id {
+		return linkDirectionSource
+	} else if l.Target == wp.Id {
+		return linkDirectionTarget
+	}
+	return ""
+}
+
+func (wp *WasmcloudProvider) isLinked(l InterfaceLinkDefinition) bool {
 	wp.lock.Lock()
 	defer wp.lock.Unlock()
-	if sourceId == wp.Id {
-		_, exists := wp.sourceLinks[target]
+	if l.SourceID == wp.Id {
+		_, exists := wp.sourceLinks[sourceLinkKey(l)]
 		return exists
-	} else if target == wp.Id {
-		_, exists := wp.targetLinks[sourceId]
+	} else if l.Target == wp.Id {
+		_, exists := wp.targetLinks[targetLinkKey(l)]
 		return exists
 	}
 	return false
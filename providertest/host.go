@@ -0,0 +1,387 @@
+// Package providertest provides an in-process fake wasmCloud host, to write fast and
+// hermetic tests for providers without a real host or NATS server.
+package providertest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"go.wasmcloud.dev/provider"
+	wrpcnats "wrpc.io/go/nats"
+)
+
+const (
+	defaultHostID      = "test-host"
+	defaultProviderKey = "test-provider"
+	defaultPrefix      = "default"
+	requestTimeout     = 5 * time.Second
+)
+
+// Host is a fake wasmCloud host backed by an embedded NATS server. It provides the host
+// data for a provider and sends it the same control messages a real host would.
+type Host struct {
+	t  testing.TB
+	nc *nats.Conn
+
+	hostData     provider.HostData
+	links        []provider.InterfaceLinkDefinition
+	hostXkey     nkeys.KeyPair
+	providerXkey nkeys.KeyPair
+	topics       provider.Topics
+}
+
+// NewHost starts an embedded NATS server and returns a host for it, stopped when the test
+// completes. Fields left empty in hostData are filled with defaults: host ID, provider key,
+// lattice prefix and URL, and the xkeys used to encrypt secrets. Links are passed to the
+// provider as part of the host data.
+func NewHost(t testing.TB, hostData provider.HostData, links ...provider.InterfaceLinkDefinition) *Host {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create nats server: %v", err)
+	}
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(requestTimeout) {
+		t.Fatal("nats server did not become ready")
+	}
+
+	if hostData.HostID == "" {
+		hostData.HostID = defaultHostID
+	}
+	if hostData.ProviderKey == "" {
+		hostData.ProviderKey = defaultProviderKey
+	}
+	if hostData.LatticeRPCPrefix == "" {
+		hostData.LatticeRPCPrefix = defaultPrefix
+	}
+	if hostData.LatticeRPCURL == "" {
+		hostData.LatticeRPCURL = ns.ClientURL()
+	}
+
+	hostXkey, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		t.Fatalf("failed to create host xkey: %v", err)
+	}
+	hostXkeyPublicKey, err := hostXkey.PublicKey()
+	if err != nil {
+		t.Fatalf("failed to create host xkey: %v", err)
+	}
+	providerXkey, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		t.Fatalf("failed to create provider xkey: %v", err)
+	}
+	providerXkeySeed, err := providerXkey.Seed()
+	if err != nil {
+		t.Fatalf("failed to create provider xkey: %v", err)
+	}
+	hostData.HostXKeyPublicKey = hostXkeyPublicKey
+	hostData.ProviderXKeyPrivateKey = provider.RedactedString(providerXkeySeed)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("failed to connect to nats server: %v", err)
+	}
+	t.Cleanup(nc.Close)
+
+	return &Host{
+		t:            t,
+		nc:           nc,
+		hostData:     hostData,
+		links:        links,
+		hostXkey:     hostXkey,
+		providerXkey: providerXkey,
+		topics:       provider.LatticeTopics(hostData, providerXkey),
+	}
+}
+
+// HostData returns the host data given to the provider.
+func (h *Host) HostData() provider.HostData {
+	return h.hostData
+}
+
+// Topics returns the lattice topics the provider subscribes to.
+func (h *Host) Topics() provider.Topics {
+	return h.topics
+}
+
+// NatsConnection returns the host's connection to the embedded NATS server.
+func (h *Host) NatsConnection() *nats.Conn {
+	return h.nc
+}
+
+// WrpcClient returns a wRPC client to invoke the functions exported by the provider.
+func (h *Host) WrpcClient() *wrpcnats.Client {
+	prefix := fmt.Sprintf("%s.%s", h.hostData.LatticeRPCPrefix, h.hostData.ProviderKey)
+	return wrpcnats.NewClient(h.nc, wrpcnats.WithPrefix(prefix))
+}
+
+// HostDataSource returns the host data encoded the way the host writes it to the provider's
+// stdin, to be passed to provider.NewWithHostDataSource.
+func (h *Host) HostDataSource() io.Reader {
+	h.t.Helper()
+
+	data, err := provider.MarshalHostData(h.hostData)
+	if err != nil {
+		h.t.Fatalf("failed to encode host data: %v", err)
+	}
+	if len(h.links) > 0 {
+		// Links are sent with their secrets encrypted for the provider
+		var raw map[string]any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			h.t.Fatalf("failed to encode host data: %v", err)
+		}
+		links := make([]map[string]any, len(h.links))
+		for i, link := range h.links {
+			links[i] = h.encryptLink(link)
+		}
+		raw["link_definitions"] = links
+		data, err = json.Marshal(raw)
+		if err != nil {
+			h.t.Fatalf("failed to encode host data: %v", err)
+		}
+	}
+	return strings.NewReader(base64.StdEncoding.EncodeToString(data) + "\n")
+}
+
+// NewProvider creates a provider from the host data and starts it in the background. The
+// provider is shut down when the test completes, unless it already was.
+func (h *Host) NewProvider(options ...provider.ProviderHandler) *provider.WasmcloudProvider {
+	h.t.Helper()
+
+	wp, err := provider.NewWithHostDataSource(h.HostDataSource(), options...)
+	if err != nil {
+		h.t.Fatalf("failed to create provider: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := wp.Start(); err != nil {
+			h.t.Errorf("failed to start provider: %v", err)
+		}
+	}()
+	h.t.Cleanup(func() {
+		_ = wp.Shutdown()
+		<-done
+	})
+
+	if err := h.WaitForProvider(requestTimeout); err != nil {
+		h.t.Fatal(err)
+	}
+	return wp
+}
+
+// WaitForProvider waits until the provider answers health checks.
+func (h *Host) WaitForProvider(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := h.nc.Request(h.topics.LATTICE_HEALTH, nil, 100*time.Millisecond)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, nats.ErrNoResponders) && !errors.Is(err, nats.ErrTimeout) {
+			return fmt.Errorf("failed to wait for provider: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("provider did not start within %s", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// PutLink sends a link to the provider, encrypting its secrets like the host does, and waits
+// for the provider to handle it. It returns the error reported by the provider, if any.
+func (h *Host) PutLink(link provider.InterfaceLinkDefinition) error {
+	data, err := json.Marshal(h.encryptLink(link))
+	if err != nil {
+		return err
+	}
+	return h.linkRequest(h.topics.LATTICE_LINK_PUT, data)
+}
+
+// DeleteLink tells the provider a link was deleted and waits for the provider to handle it.
+// It returns the error reported by the provider, if any.
+func (h *Host) DeleteLink(link provider.InterfaceLinkDefinition) error {
+	// Secrets aren't sent with deleted links
+	link.SourceSecrets = nil
+	link.TargetSecrets = nil
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	return h.linkRequest(h.topics.LATTICE_LINK_DEL, data)
+}
+
+func (h *Host) linkRequest(subject string, data []byte) error {
+	msg, err := h.nc.Request(subject, data, requestTimeout)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return fmt.Errorf("failed to decode link response: %w", err)
+	}
+	if !resp.Success {
+		return errors.New(resp.Message)
+	}
+	return nil
+}
+
+// Links returns the links reported by the provider, with their secrets redacted.
+func (h *Host) Links() ([]provider.InterfaceLinkDefinition, error) {
+	msg, err := h.nc.Request(h.topics.LATTICE_LINK_GET, nil, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []provider.InterfaceLinkDefinition
+	if err := json.Unmarshal(msg.Data, &links); err != nil {
+		return nil, fmt.Errorf("failed to decode links: %w", err)
+	}
+	return links, nil
+}
+
+// HealthCheck sends a health check request to the provider.
+func (h *Host) HealthCheck() (provider.HealthCheckResponse, error) {
+	var resp provider.HealthCheckResponse
+	msg, err := h.nc.Request(h.topics.LATTICE_HEALTH, nil, requestTimeout)
+	if err != nil {
+		return resp, err
+	}
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return resp, fmt.Errorf("failed to decode health check response: %w", err)
+	}
+	return resp, nil
+}
+
+// Shutdown sends a shutdown request to the provider and waits for it to be acknowledged.
+func (h *Host) Shutdown() error {
+	_, err := h.nc.Request(h.topics.LATTICE_SHUTDOWN, nil, requestTimeout)
+	return err
+}
+
+// AssertHealthy fails the test if the provider doesn't report itself as healthy.
+func (h *Host) AssertHealthy() provider.HealthCheckResponse {
+	h.t.Helper()
+	resp, err := h.HealthCheck()
+	if err != nil {
+		h.t.Fatalf("health check failed: %v", err)
+	}
+	if !resp.Healthy {
+		h.t.Errorf("expected provider to be healthy, got: %+v", resp)
+	}
+	return resp
+}
+
+// AssertUnhealthy fails the test if the provider doesn't report itself as unhealthy.
+func (h *Host) AssertUnhealthy() provider.HealthCheckResponse {
+	h.t.Helper()
+	resp, err := h.HealthCheck()
+	if err != nil {
+		h.t.Fatalf("health check failed: %v", err)
+	}
+	if resp.Healthy {
+		h.t.Errorf("expected provider to be unhealthy, got: %+v", resp)
+	}
+	return resp
+}
+
+// AssertLinked fails the test unless the provider reports a link with the same source,
+// target, name and WIT package as link.
+func (h *Host) AssertLinked(link provider.InterfaceLinkDefinition) {
+	h.t.Helper()
+	if !h.linked(link) {
+		h.t.Errorf("expected provider to have link %s -> %s (%s, %s:%s)", link.SourceID, link.Target, link.Name, link.WitNamespace, link.WitPackage)
+	}
+}
+
+// AssertNotLinked fails the test if the provider reports a link with the same source,
+// target, name and WIT package as link.
+func (h *Host) AssertNotLinked(link provider.InterfaceLinkDefinition) {
+	h.t.Helper()
+	if h.linked(link) {
+		h.t.Errorf("expected provider not to have link %s -> %s (%s, %s:%s)", link.SourceID, link.Target, link.Name, link.WitNamespace, link.WitPackage)
+	}
+}
+
+func (h *Host) linked(link provider.InterfaceLinkDefinition) bool {
+	h.t.Helper()
+	links, err := h.Links()
+	if err != nil {
+		h.t.Fatalf("failed to get links: %v", err)
+	}
+	return slices.ContainsFunc(links, func(l provider.InterfaceLinkDefinition) bool {
+		return l.SourceID == link.SourceID && l.Target == link.Target && l.Name == link.Name &&
+			l.WitNamespace == link.WitNamespace && l.WitPackage == link.WitPackage
+	})
+}
+
+// encryptLink returns the link in its wire format, with secrets encrypted for the provider.
+func (h *Host) encryptLink(link provider.InterfaceLinkDefinition) map[string]any {
+	h.t.Helper()
+
+	data, err := json.Marshal(provider.InterfaceLinkDefinition{
+		SourceID:     link.SourceID,
+		Target:       link.Target,
+		Name:         link.Name,
+		WitNamespace: link.WitNamespace,
+		WitPackage:   link.WitPackage,
+		Interfaces:   link.Interfaces,
+		SourceConfig: link.SourceConfig,
+		TargetConfig: link.TargetConfig,
+	})
+	if err != nil {
+		h.t.Fatalf("failed to encode link: %v", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		h.t.Fatalf("failed to encode link: %v", err)
+	}
+
+	if len(link.SourceSecrets) > 0 {
+		raw["source_secrets"] = h.encryptSecrets(link.SourceSecrets)
+	}
+	if len(link.TargetSecrets) > 0 {
+		raw["target_secrets"] = h.encryptSecrets(link.TargetSecrets)
+	}
+	return raw
+}
+
+func (h *Host) encryptSecrets(secrets map[string]provider.SecretValue) []byte {
+	h.t.Helper()
+
+	data, err := provider.MarshalSecrets(secrets)
+	if err != nil {
+		h.t.Fatalf("failed to encode secrets: %v", err)
+	}
+	providerXkeyPublicKey, err := h.providerXkey.PublicKey()
+	if err != nil {
+		h.t.Fatalf("failed to encrypt secrets: %v", err)
+	}
+	encrypted, err := h.hostXkey.Seal(data, providerXkeyPublicKey)
+	if err != nil {
+		h.t.Fatalf("failed to encrypt secrets: %v", err)
+	}
+	return encrypted
+}
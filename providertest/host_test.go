@@ -0,0 +1,91 @@
+package providertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.wasmcloud.dev/provider"
+)
+
+func TestHost(t *testing.T) {
+	initial := provider.InterfaceLinkDefinition{
+		SourceID:      "component",
+		Target:        defaultProviderKey,
+		Name:          "default",
+		WitNamespace:  "wasi",
+		WitPackage:    "keyvalue",
+		TargetConfig:  map[string]string{"bucket": "initial"},
+		TargetSecrets: map[string]provider.SecretValue{"password": provider.NewSecretString("initial")},
+	}
+	host := NewHost(t, provider.HostData{
+		Config:  map[string]string{"region": "local"},
+		Secrets: map[string]provider.SecretValue{"token": provider.NewSecretBytes([]byte{1, 2, 3})},
+	}, initial)
+
+	links := make(chan provider.InterfaceLinkDefinition, 10)
+	var cfg struct {
+		Region string `config:"region,required"`
+	}
+	wp := host.NewProvider(
+		provider.ProviderConfig(&cfg),
+		provider.TargetLinkPut(func(link provider.InterfaceLinkDefinition) error {
+			if link.TargetConfig["bucket"] == "rejected" {
+				return errors.New("rejected")
+			}
+			links <- link
+			return nil
+		}),
+		provider.HealthCheckStatus(func(context.Context) (provider.HealthStatus, error) {
+			return provider.HealthStatus{Healthy: true, Message: "ok"}, nil
+		}),
+	)
+
+	if cfg.Region != "local" {
+		t.Errorf("Expected provider config to be decoded, got %+v", cfg)
+	}
+	if token, _ := wp.Secret("token"); string(token.Bytes.Reveal()) != "\x01\x02\x03" {
+		t.Errorf("Expected provider secret to be decoded, got %v", token.Bytes.Reveal())
+	}
+	if link := <-links; link.TargetSecrets["password"].String.Reveal() != "initial" {
+		t.Errorf("Expected initial link secrets to be decrypted, got %+v", link)
+	}
+	host.AssertLinked(initial)
+
+	if resp := host.AssertHealthy(); resp.Message != "ok" {
+		t.Errorf("Expected health check message ok, got %q", resp.Message)
+	}
+
+	link := provider.InterfaceLinkDefinition{
+		SourceID:      "other",
+		Target:        defaultProviderKey,
+		Name:          "default",
+		WitNamespace:  "wasi",
+		WitPackage:    "keyvalue",
+		TargetSecrets: map[string]provider.SecretValue{"key": provider.NewSecretBytes([]byte("key"))},
+	}
+	if err := host.PutLink(link); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	if got := <-links; string(got.TargetSecrets["key"].Bytes.Reveal()) != "key" {
+		t.Errorf("Expected link secrets to be decrypted, got %+v", got)
+	}
+	host.AssertLinked(link)
+
+	rejected := link
+	rejected.SourceID = "rejected"
+	rejected.TargetConfig = map[string]string{"bucket": "rejected"}
+	if err := host.PutLink(rejected); err == nil || err.Error() != "rejected" {
+		t.Errorf("Expected the provider error to be returned, got: %v", err)
+	}
+	host.AssertNotLinked(rejected)
+
+	if err := host.DeleteLink(link); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	host.AssertNotLinked(link)
+
+	if err := host.Shutdown(); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+}
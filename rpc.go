@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	wrpc "wrpc.io/go"
+)
+
+// rpcServer wraps the provider's wRPC server, keeping track of the exports served and of
+// in-flight invocations, so they can be stopped and drained on shutdown.
+type rpcServer struct {
+	server wrpc.Server
+	// logger is the base of the invocation loggers, see LoggerFromContext
+	logger *slog.Logger
+
+	lock     sync.Mutex
+	stopped  bool
+	stops    []func() error
+	inFlight int
+	// idle is closed when the last in-flight invocation completes
+	idle chan struct{}
+}
+
+func newRPCServer(server wrpc.Server, logger *slog.Logger) *rpcServer {
+	return &rpcServer{server: server, logger: logger}
+}
+
+func (s *rpcServer) Serve(instance string, name string, f func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser), paths ...wrpc.SubscribePath) (func() error, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return nil, errors.New("provider is shutting down")
+	}
+
+	stop, err := s.server.Serve(instance, name, func(ctx context.Context, w wrpc.IndexWriteCloser, r wrpc.IndexReadCloser) {
+		s.start()
+		defer s.done()
+		f(contextWithInvocationLogger(ctx, s.logger, instance, name), w, r)
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exports can be stopped by the caller and by the shutdown, only stop them once
+	stop = sync.OnceValue(stop)
+	s.stops = append(s.stops, stop)
+	return stop, nil
+}
+
+func (s *rpcServer) start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.inFlight == 0 {
+		s.idle = make(chan struct{})
+	}
+	s.inFlight++
+}
+
+func (s *rpcServer) done() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.inFlight--
+	if s.inFlight == 0 {
+		close(s.idle)
+	}
+}
+
+// stop stops serving all exports, so no new invocations are accepted.
+func (s *rpcServer) stop() error {
+	s.lock.Lock()
+	s.stopped = true
+	stops := s.stops
+	s.lock.Unlock()
+
+	var errs []error
+	for _, stop := range stops {
+		if err := stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// wait waits for all in-flight invocations to complete, or for ctx to be done.
+func (s *rpcServer) wait(ctx context.Context) error {
+	s.lock.Lock()
+	if s.inFlight == 0 {
+		s.lock.Unlock()
+		return nil
+	}
+	idle := s.idle
+	inFlight := s.inFlight
+	s.lock.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d in-flight invocation(s) didn't complete: %w", inFlight, ctx.Err())
+	}
+}
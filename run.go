@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	wrpc "wrpc.io/go"
+)
+
+// ExitReason describes why a running provider stopped.
+type ExitReason string
+
+const (
+	// The host requested the provider to shut down
+	ExitReasonHostShutdown ExitReason = "host shutdown"
+	// The process received SIGINT or SIGTERM
+	ExitReasonSignal ExitReason = "signal"
+	// The lattice connection was closed and couldn't be re-established
+	ExitReasonConnectionLost ExitReason = "connection lost"
+	// The provider failed to start or serve its exports
+	ExitReasonFatal ExitReason = "fatal error"
+	// The context passed to StartContext or Run was done
+	ExitReasonContextDone ExitReason = "context done"
+	// Shutdown was called
+	ExitReasonShutdown ExitReason = "shutdown"
+)
+
+// ServeFunc serves provider exports, ex: with the Serve function of generated bindings:
+//
+//	func(s wrpc.Server) (func() error, error) { return server.Serve(s, handler) }
+type ServeFunc func(s wrpc.Server) (stop func() error, err error)
+
+// Start starts handling the lattice control messages (links, health checks and shutdown)
+// and blocks until the provider shuts down. It only returns an error if the provider fails
+// to start.
+func (wp *WasmcloudProvider) Start() error {
+	reason, err := wp.StartContext(context.Background())
+	if reason != ExitReasonFatal {
+		return nil
+	}
+	return err
+}
+
+// StartContext is like Start, but also shuts the provider down when ctx is done. It returns
+// why the provider stopped, along with any error from starting or shutting down.
+func (wp *WasmcloudProvider) StartContext(ctx context.Context) (ExitReason, error) {
+	return wp.run(ctx, nil)
+}
+
+// Run serves the provider exports and starts the provider, shutting it down when ctx is done,
+// the host requests it, or the process receives SIGINT or SIGTERM. It returns why the
+// provider stopped, along with any error from starting or shutting down. A provider's main
+// function can be as small as:
+//
+//	p, err := provider.New(...)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if _, err := p.Run(context.Background(), serve); err != nil {
+//		log.Fatal(err)
+//	}
+func (wp *WasmcloudProvider) Run(ctx context.Context, serve ...ServeFunc) (ExitReason, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for _, serveFunc := range serve {
+		if _, err := serveFunc(wp.RPCServer()); err != nil {
+			wp.Logger.Error("failed to serve exports", slog.Any("error", err))
+			_ = wp.shutdown(ExitReasonFatal, nil)
+			return ExitReasonFatal, err
+		}
+	}
+
+	return wp.run(ctx, signals)
+}
+
+func (wp *WasmcloudProvider) run(ctx context.Context, signals <-chan os.Signal) (ExitReason, error) {
+	for _, link := range wp.SourceLinks() {
+		err := wp.putSourceLinkFunc(link)
+		wp.metrics.recordLinkEvent(wp.context, linkDirectionSource, linkOperationPut, err)
+		if err != nil {
+			wp.Logger.Error("failed to invoke source link function", slog.Any("error", err))
+		}
+	}
+	for _, link := range wp.TargetLinks() {
+		err := wp.putTargetLinkFunc(link)
+		wp.metrics.recordLinkEvent(wp.context, linkDirectionTarget, linkOperationPut, err)
+		if err != nil {
+			wp.Logger.Error("failed to invoke target link function", slog.Any("error", err))
+		}
+	}
+
+	err := wp.subToNats()
+	if err != nil {
+		_ = wp.shutdown(ExitReasonFatal, nil)
+		return ExitReasonFatal, err
+	}
+
+	if wp.linksWatcher != nil {
+		wp.linksWatcher.start(wp)
+	}
+
+	wp.Logger.Info("provider started", "id", wp.Id)
+	select {
+	case <-ctx.Done():
+		err = wp.shutdown(ExitReasonContextDone, nil)
+	case sig := <-signals:
+		wp.Logger.Info("received signal", "signal", sig.String())
+		err = wp.shutdown(ExitReasonSignal, nil)
+	case <-wp.context.Done():
+		// Already shut down, ex: at the host's request
+		err = wp.shutdown(ExitReasonShutdown, nil)
+	}
+
+	reason := wp.exitReason()
+	wp.Logger.Info("provider exiting", "id", wp.Id, "reason", string(reason))
+	return reason, err
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	nats "github.com/nats-io/nats.go"
+	wrpc "wrpc.io/go"
+)
+
+type runResult struct {
+	reason ExitReason
+	err    error
+}
+
+func waitForExit(t *testing.T, ch <-chan runResult) runResult {
+	t.Helper()
+	select {
+	case res := <-ch:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the provider to exit")
+		return runResult{}
+	}
+}
+
+// waitForStart waits until the provider answers health checks.
+func waitForStart(t *testing.T, wp *WasmcloudProvider) {
+	t.Helper()
+	for range 100 {
+		_, err := wp.NatsConnection().Request(wp.Topics.LATTICE_HEALTH, nil, time.Second)
+		if err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("provider did not start")
+}
+
+func TestStartContext(t *testing.T) {
+	var hooks int
+	wp := newTestProvider(t, Shutdown(func() error { hooks++; return nil }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.StartContext(ctx)
+		exited <- runResult{reason, err}
+	}()
+	waitForStart(t, wp)
+
+	cancel()
+	res := waitForExit(t, exited)
+	if res.reason != ExitReasonContextDone || res.err != nil {
+		t.Errorf("Expected to exit because the context is done, got %q and err %v", res.reason, res.err)
+	}
+	if hooks != 1 || !wp.NatsConnection().IsClosed() {
+		t.Errorf("Expected the provider to be shut down, got %d hook calls", hooks)
+	}
+}
+
+func TestStartContextShutdown(t *testing.T) {
+	wp := newTestProvider(t, Shutdown(func() error { return errors.New("hook failed") }))
+
+	exited := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.StartContext(context.Background())
+		exited <- runResult{reason, err}
+	}()
+	waitForStart(t, wp)
+
+	if err := wp.Shutdown(); err == nil {
+		t.Error("Expected shutdown to fail")
+	}
+	res := waitForExit(t, exited)
+	if res.reason != ExitReasonShutdown || res.err == nil {
+		t.Errorf("Expected to exit because of the shutdown with its error, got %q and err %v", res.reason, res.err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	wp := newTestProvider(t)
+
+	var served wrpc.Server
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exited := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.Run(ctx, func(s wrpc.Server) (func() error, error) {
+			served = s
+			return func() error { return nil }, nil
+		})
+		exited <- runResult{reason, err}
+	}()
+	waitForStart(t, wp)
+
+	if served != wp.RPCServer() {
+		t.Error("Expected exports to be served with the provider RPC server")
+	}
+
+	cancel()
+	res := waitForExit(t, exited)
+	if res.reason != ExitReasonContextDone || res.err != nil {
+		t.Errorf("Expected to exit because the context is done, got %q and err %v", res.reason, res.err)
+	}
+}
+
+func TestRunSignal(t *testing.T) {
+	wp := newTestProvider(t)
+
+	signals := make(chan os.Signal, 1)
+	exited := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.run(context.Background(), signals)
+		exited <- runResult{reason, err}
+	}()
+	waitForStart(t, wp)
+
+	signals <- os.Interrupt
+	res := waitForExit(t, exited)
+	if res.reason != ExitReasonSignal || res.err != nil {
+		t.Errorf("Expected to exit because of the signal, got %q and err %v", res.reason, res.err)
+	}
+}
+
+func TestRunServeError(t *testing.T) {
+	wp := newTestProvider(t)
+
+	reason, err := wp.Run(context.Background(), func(wrpc.Server) (func() error, error) {
+		return nil, errors.New("invalid export")
+	})
+	if reason != ExitReasonFatal || err == nil || err.Error() != "invalid export" {
+		t.Errorf("Expected a fatal error, got %q and err %v", reason, err)
+	}
+	if !wp.NatsConnection().IsClosed() {
+		t.Error("Expected the provider to be shut down")
+	}
+}
+
+func TestRunConnectionLost(t *testing.T) {
+	ns := newNatsServer(t, &server.Options{})
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    ns.ClientURL(),
+	}
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData), NatsOptions(nats.NoReconnect()))
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	exited := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.Run(context.Background())
+		exited <- runResult{reason, err}
+	}()
+	waitForStart(t, wp)
+
+	ns.Shutdown()
+	res := waitForExit(t, exited)
+	if res.reason != ExitReasonConnectionLost {
+		t.Errorf("Expected to exit because the connection was lost, got %q and err %v", res.reason, res.err)
+	}
+}
@@ -3,6 +3,9 @@ package provider
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
 
 	"github.com/nats-io/nkeys"
 )
@@ -21,6 +24,14 @@ func (s SecretStringValue) Reveal() string {
 	return s.value
 }
 
+func (s SecretStringValue) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s SecretStringValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
 type SecretBytesValue struct {
 	value []byte
 }
@@ -33,27 +44,109 @@ func (s SecretBytesValue) Reveal() []byte {
 	return s.value
 }
 
+func (s SecretBytesValue) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s SecretBytesValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// SecretKind is the kind of value held by a SecretValue.
+type SecretKind string
+
+const (
+	SecretKindString SecretKind = "String"
+	SecretKindBytes  SecretKind = "Bytes"
+)
+
 type SecretValue struct {
 	String SecretStringValue
 	Bytes  SecretBytesValue
 }
 
+// NewSecretString returns a SecretValue holding a string.
+func NewSecretString(value string) SecretValue {
+	return SecretValue{String: SecretStringValue{value: value}}
+}
+
+// NewSecretBytes returns a SecretValue holding bytes.
+func NewSecretBytes(value []byte) SecretValue {
+	if value == nil {
+		value = []byte{}
+	}
+	return SecretValue{Bytes: SecretBytesValue{value: value}}
+}
+
+// Kind returns the kind of value held by the secret. A secret holds bytes if it was decoded
+// from (or created with) a bytes value, and a string otherwise.
+func (s SecretValue) Kind() SecretKind {
+	if s.Bytes.value != nil {
+		return SecretKindBytes
+	}
+	return SecretKindString
+}
+
+// IsString reports whether the secret holds a string.
+func (s SecretValue) IsString() bool {
+	return s.Kind() == SecretKindString
+}
+
+// IsBytes reports whether the secret holds bytes.
+func (s SecretValue) IsBytes() bool {
+	return s.Kind() == SecretKindBytes
+}
+
+func (s SecretValue) LogValue() slog.Value {
+	if s.IsBytes() {
+		return s.Bytes.LogValue()
+	}
+	return s.String.LogValue()
+}
+
+type jsonSecretValue struct {
+	Kind  SecretKind      `json:"kind"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSON serializes the secret with its kind and a redacted value, e.g.
+// {"kind": "String", "value": "redacted(string)"}
+func (s SecretValue) MarshalJSON() ([]byte, error) {
+	var value any = s.String
+	if s.IsBytes() {
+		value = s.Bytes
+	}
+	return json.Marshal(struct {
+		Kind  SecretKind `json:"kind"`
+		Value any        `json:"value"`
+	}{Kind: s.Kind(), Value: value})
+}
+
 // Secret values are serialized as either a String or Bytes value, e.g.
-// {"kind": "String", "value": "my secret"} or {"kind": "Bytes", "value": [1, 2, 3]}
+// {"kind": "String", "value": "my secret"} or {"kind": "Bytes", "value": [1, 2, 3]}.
+// Bytes values may also be a base64 encoded string.
 func (s *SecretValue) UnmarshalJSON(data []byte) error {
-	var jsonSecret map[string]interface{}
+	var jsonSecret jsonSecretValue
 	err := json.Unmarshal(data, &jsonSecret)
 	if err != nil {
 		return err
 	}
 
-	switch jsonSecret["kind"] {
-	case "String":
-		s.String = SecretStringValue{value: jsonSecret["value"].(string)}
-	case "Bytes":
-		s.Bytes = SecretBytesValue{value: jsonSecret["value"].([]byte)}
+	switch jsonSecret.Kind {
+	case SecretKindString:
+		var value string
+		if err := json.Unmarshal(jsonSecret.Value, &value); err != nil {
+			return fmt.Errorf("invalid String secret value: %w", err)
+		}
+		*s = NewSecretString(value)
+	case SecretKindBytes:
+		var value []byte
+		if err := json.Unmarshal(jsonSecret.Value, &value); err != nil {
+			return fmt.Errorf("invalid Bytes secret value: %w", err)
+		}
+		*s = NewSecretBytes(value)
 	default:
-		return fmt.Errorf("invalid secret kind: %s", jsonSecret["kind"])
+		return fmt.Errorf("invalid secret kind: %s", jsonSecret.Kind)
 	}
 
 	return nil
@@ -84,3 +177,121 @@ func DecryptSecrets(encryptedBytes *[]byte, xkey nkeys.KeyPair, sender string) (
 	}
 	return sourceSecrets, nil
 }
+
+// MarshalSecrets returns secrets as the JSON the host encrypts for the provider, see
+// DecryptSecrets. Unlike json.Marshal, the real values of the secrets are kept.
+func MarshalSecrets(secrets map[string]SecretValue) ([]byte, error) {
+	return json.Marshal(revealSecrets(secrets))
+}
+
+// revealSecrets returns secrets in the format sent by the host, with their real values.
+func revealSecrets(secrets map[string]SecretValue) map[string]any {
+	revealed := make(map[string]any, len(secrets))
+	for name, secret := range secrets {
+		var value any = secret.String.Reveal()
+		if secret.IsBytes() {
+			// Bytes are sent as an array of numbers, not base64
+			items := make([]int, len(secret.Bytes.Reveal()))
+			for i, b := range secret.Bytes.Reveal() {
+				items[i] = int(b)
+			}
+			value = items
+		}
+		revealed[name] = map[string]any{"kind": secret.Kind(), "value": value}
+	}
+	return revealed
+}
+
+// SecretsChange describes the secrets of a link that changed when the link was put again,
+// ex: after a credential rotation.
+type SecretsChange struct {
+	// Link is the updated link definition, holding the new secrets
+	Link InterfaceLinkDefinition
+	// Changed holds the names of the secrets that were added or have a new value
+	Changed []string
+	// Removed holds the names of the secrets that are no longer present
+	Removed []string
+}
+
+// diffSecrets compares the provider side secrets of a link before and after an update,
+// returning nil if they're the same.
+func diffSecrets(link InterfaceLinkDefinition, old map[string]SecretValue, new map[string]SecretValue) *SecretsChange {
+	change := SecretsChange{Link: link}
+	for name, value := range new {
+		if oldValue, ok := old[name]; !ok || !secretValuesEqual(oldValue, value) {
+			change.Changed = append(change.Changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			change.Removed = append(change.Removed, name)
+		}
+	}
+	if len(change.Changed) == 0 && len(change.Removed) == 0 {
+		return nil
+	}
+
+	sort.Strings(change.Changed)
+	sort.Strings(change.Removed)
+	return &change
+}
+
+// refreshSecrets updates the provider secrets with the rotated secrets of a link.
+func (wp *WasmcloudProvider) refreshSecrets(change SecretsChange) {
+	secrets := change.Link.TargetSecrets
+	if change.Link.SourceID == wp.Id {
+		secrets = change.Link.SourceSecrets
+	}
+
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.secrets == nil {
+		wp.secrets = make(map[string]SecretValue, len(change.Changed))
+	}
+	for _, name := range change.Changed {
+		wp.secrets[name] = secrets[name]
+	}
+	for _, name := range change.Removed {
+		delete(wp.secrets, name)
+	}
+}
+
+func (wp *WasmcloudProvider) notifySecretsChanged(change SecretsChange) {
+	for _, secretsChangedFunc := range wp.secretsChangedFuncs {
+		err := secretsChangedFunc(SecretsChange{
+			Link:    change.Link.clone(),
+			Changed: slices.Clone(change.Changed),
+			Removed: slices.Clone(change.Removed),
+		})
+		if err != nil {
+			wp.Logger.Error("failed to handle secrets change", slog.Any("error", err), "link", change.Link.redacted())
+		}
+	}
+}
+
+// Secret returns the current value of the provider secret named name. Secrets are provided
+// by the host when the provider starts, and refreshed with the provider side secrets of links
+// put again with rotated secrets, see SecretsChanged.
+func (wp *WasmcloudProvider) Secret(name string) (SecretValue, bool) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	secret, ok := wp.secrets[name]
+	return secret, ok
+}
+
+// LinkSecret returns the current value of the secret named name on the provider's side of
+// link, ex: the target secrets when the provider is the target. Long lived clients created
+// from a link can use it to pick up rotated secrets.
+func (wp *WasmcloudProvider) LinkSecret(link InterfaceLinkDefinition, name string) (SecretValue, bool) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+
+	var secrets map[string]SecretValue
+	if link.SourceID == wp.Id {
+		secrets = wp.sourceLinks[sourceLinkKey(link)].SourceSecrets
+	} else if link.Target == wp.Id {
+		secrets = wp.targetLinks[targetLinkKey(link)].TargetSecrets
+	}
+	secret, ok := secrets[name]
+	return secret, ok
+}
@@ -1,8 +1,16 @@
 package provider
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/nats-io/nats-server/v2/server"
 )
 
 func TestUnmarshalJson(t *testing.T) {
@@ -44,3 +52,278 @@ func TestUnmarshalJsonMap(t *testing.T) {
 		t.Errorf("Unexpected value. Got: %s, Expected: %s", secret["foobar"].String.Reveal(), expectedValue)
 	}
 }
+
+func TestUnmarshalJsonKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		kind     SecretKind
+		str      string
+		bytes    []byte
+		expectOk bool
+	}{
+		{name: "String", json: `{"kind": "String", "value": "secret"}`, kind: SecretKindString, str: "secret", expectOk: true},
+		{name: "Empty string", json: `{"kind": "String", "value": ""}`, kind: SecretKindString, expectOk: true},
+		{name: "Byte array", json: `{"kind": "Bytes", "value": [0, 1, 255]}`, kind: SecretKindBytes, bytes: []byte{0, 1, 255}, expectOk: true},
+		{name: "Empty byte array", json: `{"kind": "Bytes", "value": []}`, kind: SecretKindBytes, bytes: []byte{}, expectOk: true},
+		{name: "Base64 bytes", json: `{"kind": "Bytes", "value": "AAH/"}`, kind: SecretKindBytes, bytes: []byte{0, 1, 255}, expectOk: true},
+		{name: "Unknown kind", json: `{"kind": "Number", "value": 1}`},
+		{name: "Missing kind", json: `{"value": "secret"}`},
+		{name: "Missing value", json: `{"kind": "String"}`},
+		{name: "String with non-string value", json: `{"kind": "String", "value": 1}`},
+		{name: "Byte out of range", json: `{"kind": "Bytes", "value": [256]}`},
+		{name: "Bytes with non-numeric items", json: `{"kind": "Bytes", "value": ["a"]}`},
+		{name: "Invalid base64", json: `{"kind": "Bytes", "value": "redacted(bytes)"}`},
+		{name: "Not an object", json: `"secret"`},
+	}
+
+	for _, tc := range tests {
+		var secret SecretValue
+		err := json.Unmarshal([]byte(tc.json), &secret)
+		if !tc.expectOk {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", tc.name, secret)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected err to be nil, got: %v", tc.name, err)
+			continue
+		}
+
+		if secret.Kind() != tc.kind {
+			t.Errorf("%s: expected kind %s, got %s", tc.name, tc.kind, secret.Kind())
+		}
+		if secret.IsString() != (tc.kind == SecretKindString) || secret.IsBytes() != (tc.kind == SecretKindBytes) {
+			t.Errorf("%s: IsString/IsBytes don't match kind %s", tc.name, tc.kind)
+		}
+		if secret.String.Reveal() != tc.str {
+			t.Errorf("%s: expected string %q, got %q", tc.name, tc.str, secret.String.Reveal())
+		}
+		if !bytes.Equal(secret.Bytes.Reveal(), tc.bytes) {
+			t.Errorf("%s: expected bytes %v, got %v", tc.name, tc.bytes, secret.Bytes.Reveal())
+		}
+	}
+}
+
+func TestSecretValueRoundTrip(t *testing.T) {
+	secrets := map[string]SecretValue{
+		"string":      NewSecretString("secret"),
+		"empty":       NewSecretString(""),
+		"bytes":       NewSecretBytes([]byte{0, 1, 2, 255}),
+		"empty-bytes": NewSecretBytes(nil),
+	}
+
+	// Encode the secrets the way the host does, with their real values
+	wire := make(map[string]map[string]any, len(secrets))
+	for name, secret := range secrets {
+		var value any = secret.String.Reveal()
+		if secret.IsBytes() {
+			items := make([]int, len(secret.Bytes.Reveal()))
+			for i, b := range secret.Bytes.Reveal() {
+				items[i] = int(b)
+			}
+			value = items
+		}
+		wire[name] = map[string]any{"kind": secret.Kind(), "value": value}
+	}
+	data, err := json.Marshal(wire)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+
+	var decoded map[string]SecretValue
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if len(decoded) != len(secrets) {
+		t.Fatalf("Expected %d secrets, got %d", len(secrets), len(decoded))
+	}
+	for name, secret := range secrets {
+		if !secretValuesEqual(decoded[name], secret) {
+			t.Errorf("%s: expected secret to round trip, got kind %s", name, decoded[name].Kind())
+		}
+	}
+
+	// Marshalling keeps the kind but redacts the value
+	redacted, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+	for _, value := range []string{"secret", "AAEC/w=="} {
+		if strings.Contains(string(redacted), value) {
+			t.Errorf("Expected secret values to be redacted, got %s", redacted)
+		}
+	}
+
+	var redactedWire map[string]jsonSecretValue
+	if err := json.Unmarshal(redacted, &redactedWire); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	for name, secret := range secrets {
+		expected := `"redacted(string)"`
+		if secret.IsBytes() {
+			expected = `"redacted(bytes)"`
+		}
+		if redactedWire[name].Kind != secret.Kind() || string(redactedWire[name].Value) != expected {
+			t.Errorf("%s: expected redacted %s value, got %+v", name, secret.Kind(), redactedWire[name])
+		}
+	}
+
+	// Redacted values are plain strings, so nothing can be recovered from them
+	var secret SecretValue
+	if err := json.Unmarshal(redactedWire["string"].Value, &secret.String); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if secret.String.Reveal() != "redacted(string)" {
+		t.Errorf("Expected redacted string, got %q", secret.String.Reveal())
+	}
+}
+
+func TestSecretValueLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("secrets",
+		"string", NewSecretString("secret"),
+		"bytes", NewSecretBytes([]byte("secret")),
+		"map", map[string]SecretValue{"key": NewSecretString("secret")},
+	)
+
+	out := buf.String()
+	if strings.Contains(out, "secret\"") || strings.Contains(out, "c2VjcmV0") {
+		t.Errorf("Expected secrets to be redacted in logs, got %s", out)
+	}
+	for _, s := range []string{`"string":"redacted(string)"`, `"bytes":"redacted(bytes)"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected logs to contain %s, got %s", s, out)
+		}
+	}
+	if got := fmt.Sprintf("%v", NewSecretString("secret")); strings.Contains(got, "secret}") {
+		t.Errorf("Expected formatted secret to be redacted, got %s", got)
+	}
+}
+
+func TestProviderSecret(t *testing.T) {
+	hostData := HostData{
+		ProviderKey:      "provider",
+		LatticeRPCPrefix: "default",
+		LatticeRPCURL:    startNatsServer(t, &server.Options{}),
+		Secrets: map[string]SecretValue{
+			"token": NewSecretString("token"),
+			"key":   NewSecretBytes([]byte{1, 2, 3}),
+		},
+	}
+	wp, err := NewWithHostDataSource(hostDataSource(t, hostData))
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	t.Cleanup(wp.NatsConnection().Close)
+
+	if secret, ok := wp.Secret("token"); !ok || secret.String.Reveal() != "token" {
+		t.Errorf("Expected token secret, got %v", secret.String.Reveal())
+	}
+	if secret, ok := wp.Secret("key"); !ok || !bytes.Equal(secret.Bytes.Reveal(), []byte{1, 2, 3}) {
+		t.Errorf("Expected key secret, got %v", secret.Bytes.Reveal())
+	}
+	if _, ok := wp.Secret("missing"); ok {
+		t.Error("Expected missing secret not to be found")
+	}
+
+	// Rotated link secrets refresh the provider secrets
+	link := InterfaceLinkDefinition{
+		SourceID:      "component",
+		Target:        "provider",
+		Name:          "default",
+		WitNamespace:  "wasmcloud",
+		WitPackage:    "postgres",
+		TargetSecrets: map[string]SecretValue{"token": NewSecretString("token")},
+	}
+	rotated := link.clone()
+	rotated.TargetSecrets["token"] = NewSecretString("rotated")
+	for _, l := range []InterfaceLinkDefinition{link, rotated} {
+		if err := wp.putLink(l); err != nil {
+			t.Fatalf("Expected err to be nil, got: %v", err)
+		}
+	}
+	if secret, ok := wp.Secret("token"); !ok || secret.String.Reveal() != "rotated" {
+		t.Errorf("Expected the rotated token secret, got %v", secret.String.Reveal())
+	}
+	if _, ok := wp.Secret("key"); !ok {
+		t.Error("Expected the other secrets to be kept")
+	}
+}
+
+func TestSecretsChanged(t *testing.T) {
+	link := InterfaceLinkDefinition{
+		SourceID:     "component",
+		Target:       "provider",
+		Name:         "default",
+		WitNamespace: "wasmcloud",
+		WitPackage:   "postgres",
+		TargetConfig: map[string]string{"host": "db-1"},
+		TargetSecrets: map[string]SecretValue{
+			"password": NewSecretString("first"),
+			"cert":     NewSecretBytes([]byte("cert")),
+		},
+	}
+	rotated := link.clone()
+	rotated.TargetSecrets = map[string]SecretValue{
+		"password": NewSecretString("second"),
+		"token":    NewSecretString("token"),
+	}
+	reconfigured := rotated.clone()
+	reconfigured.TargetConfig = map[string]string{"host": "db-2"}
+	reconfigured.TargetSecrets["password"] = NewSecretString("third")
+
+	var puts, dels, updates int
+	var changes []SecretsChange
+	var current, registry []string
+	var wp *WasmcloudProvider
+	wp = newTestProvider(t,
+		TargetLinkPut(func(InterfaceLinkDefinition) error { puts++; return nil }),
+		TargetLinkDel(func(InterfaceLinkDefinition) error { dels++; return nil }),
+		TargetLinkUpdate(func(old, new InterfaceLinkDefinition) error { updates++; return nil }),
+		SecretsChanged(func(change SecretsChange) error {
+			changes = append(changes, change)
+			// Subscribers can look up the latest secrets
+			secret, _ := wp.LinkSecret(link, "password")
+			current = append(current, secret.String.Reveal())
+			secret, _ = wp.Secret("password")
+			registry = append(registry, secret.String.Reveal())
+			return nil
+		}),
+		SecretsChanged(func(SecretsChange) error { return errors.New("ignored") }),
+	)
+
+	for _, l := range []InterfaceLinkDefinition{link, rotated, rotated.clone(), reconfigured} {
+		if err := wp.putLink(l); err != nil {
+			t.Fatalf("Expected err to be nil, got: %v", err)
+		}
+	}
+
+	// The update handler is called for secrets only changes too
+	if puts != 1 || dels != 0 || updates != 2 {
+		t.Errorf("Expected 1 put, 0 deletes and 2 updates, got %d, %d and %d", puts, dels, updates)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 secrets changes, got %d", len(changes))
+	}
+	if !slices.Equal(changes[0].Changed, []string{"password", "token"}) || !slices.Equal(changes[0].Removed, []string{"cert"}) {
+		t.Errorf("Expected password and token to change and cert to be removed, got %+v", changes[0])
+	}
+	if !slices.Equal(changes[1].Changed, []string{"password"}) || len(changes[1].Removed) != 0 {
+		t.Errorf("Expected password to change, got %+v", changes[1])
+	}
+	if changes[1].Link.TargetConfig["host"] != "db-2" {
+		t.Errorf("Expected the change to hold the updated link, got %+v", changes[1].Link)
+	}
+	if !slices.Equal(current, []string{"second", "third"}) || !slices.Equal(registry, current) {
+		t.Errorf("Expected the secrets to be updated before notifying, got %v and %v", current, registry)
+	}
+	if _, ok := wp.Secret("cert"); ok {
+		t.Error("Expected removed secret not to be found")
+	}
+	if _, ok := wp.LinkSecret(link, "cert"); ok {
+		t.Error("Expected removed secret not to be found")
+	}
+}
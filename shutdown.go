@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+// shutdown gracefully shuts the provider down, once. Later calls wait for the first one
+// to complete and return its result. The phases are run in order, all within the shutdown
+// timeout, and all errors are returned joined. Once the timeout expires, the remaining phases
+// give up on waiting, ex: the connection is closed without being drained:
+//
+//  1. stop serving exports, so no new invocations are accepted, and stop reloading the
+//     links file in dev mode
+//  2. wait for in-flight invocations to complete
+//  3. run the user provided shutdown function
+//  4. flush and shut down telemetry
+//  5. drain and close the lattice connection
+//
+// reason is recorded if this is the first call. afterShutdownFunc, if not nil, is called
+// once the user provided shutdown function returned, ex: to acknowledge a shutdown request
+// from the host.
+func (wp *WasmcloudProvider) shutdown(reason ExitReason, afterShutdownFunc func()) error {
+	first := false
+	wp.shutdownOnce.Do(func() {
+		first = true
+		wp.shutdownReason.Store(reason)
+		wp.shutdownErr = wp.runShutdown(afterShutdownFunc)
+	})
+	if !first && afterShutdownFunc != nil {
+		afterShutdownFunc()
+	}
+	return wp.shutdownErr
+}
+
+func (wp *WasmcloudProvider) runShutdown(afterShutdownFunc func()) error {
+	// The provider context is cancelled last, so phases use their own context
+	defer wp.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), wp.shutdownTimeout)
+	defer cancel()
+
+	wp.Logger.Info("shutting down provider", "id", wp.Id, "reason", string(wp.exitReason()))
+	var errs []error
+
+	if wp.linksWatcher != nil {
+		wp.linksWatcher.stop()
+	}
+	if err := wp.rpcServer.stop(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop serving exports: %w", err))
+	}
+	if err := wp.rpcServer.wait(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to wait for in-flight invocations: %w", err))
+	}
+
+	if err := runWithContext(ctx, wp.shutdownFunc); err != nil {
+		errs = append(errs, fmt.Errorf("provider shutdown function failed: %w", err))
+	}
+	if afterShutdownFunc != nil {
+		afterShutdownFunc()
+	}
+
+	var telemetryErrs []error
+	for _, shutdownFunc := range wp.internalShutdownFuncs {
+		telemetryErrs = append(telemetryErrs, shutdownFunc(ctx))
+	}
+	if err := errors.Join(telemetryErrs...); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shut down telemetry: %w", err))
+	}
+
+	if err := wp.closeConnection(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close lattice connection: %w", err))
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		wp.Logger.Error("provider shutdown failed", slog.Any("error", err))
+	}
+	return err
+}
+
+// closeConnection drains the lattice subscriptions and connection, waiting for the
+// connection to be closed. If ctx is done first, the connection is closed immediately.
+func (wp *WasmcloudProvider) closeConnection(ctx context.Context) error {
+	if wp.natsConnection.IsClosed() {
+		return nil
+	}
+
+	err := wp.cleanupNatsSubscriptions()
+	if err != nil {
+		wp.natsConnection.Close()
+		return err
+	}
+
+	select {
+	case <-wp.connectionClosed:
+		return nil
+	case <-ctx.Done():
+		wp.natsConnection.Close()
+		return fmt.Errorf("timed out draining connection: %w", ctx.Err())
+	}
+}
+
+// runWithContext runs f, returning early with an error if ctx is done first. f keeps running
+// in the background in that case.
+func runWithContext(ctx context.Context, f func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- f()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// exitReason returns why the provider shut down, or an empty reason if it didn't.
+func (wp *WasmcloudProvider) exitReason() ExitReason {
+	reason, _ := wp.shutdownReason.Load().(ExitReason)
+	return reason
+}
@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	wrpc "wrpc.io/go"
+)
+
+// fakeServer is a wRPC server recording the served handlers, to invoke them directly.
+type fakeServer struct {
+	lock     sync.Mutex
+	handlers map[string]func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser)
+	stops    int
+}
+
+func (s *fakeServer) Serve(instance string, name string, f func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser), paths ...wrpc.SubscribePath) (func() error, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser))
+	}
+	s.handlers[instance+"."+name] = f
+	return func() error {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.stops++
+		return nil
+	}, nil
+}
+
+func (s *fakeServer) invoke(name string) {
+	s.lock.Lock()
+	f := s.handlers[name]
+	s.lock.Unlock()
+	f(context.Background(), nil, nil)
+}
+
+func TestShutdown(t *testing.T) {
+	var events []string
+	var lock sync.Mutex
+	record := func(event string) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	}
+
+	wp := newTestProvider(t, Shutdown(func() error {
+		record("shutdown hook")
+		return nil
+	}))
+	wp.internalShutdownFuncs = append(wp.internalShutdownFuncs, func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			t.Errorf("Expected telemetry to be flushed with a live context, got: %v", ctx.Err())
+		}
+		record("telemetry")
+		return nil
+	})
+	server := &fakeServer{}
+	wp.rpcServer = newRPCServer(server, wp.Logger)
+
+	stop, err := wp.RPCServer().Serve("wasi:keyvalue/store", "get", func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser) {
+		time.Sleep(50 * time.Millisecond)
+		record("invocation")
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+
+	invoked := make(chan struct{})
+	go func() {
+		defer close(invoked)
+		server.invoke("wasi:keyvalue/store.get")
+	}()
+	// Let the invocation start
+	time.Sleep(10 * time.Millisecond)
+
+	if err := wp.Shutdown(); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	<-invoked
+
+	expected := []string{"invocation", "shutdown hook", "telemetry"}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected shutdown phases %v, got %v", expected, events)
+	}
+	if server.stops != 1 {
+		t.Errorf("Expected exports to be stopped once, got %d", server.stops)
+	}
+	if !wp.NatsConnection().IsClosed() {
+		t.Error("Expected lattice connection to be closed")
+	}
+	if wp.context.Err() == nil {
+		t.Error("Expected provider context to be cancelled")
+	}
+
+	// Shutting down again, or stopping the exports, doesn't do anything
+	if err := wp.Shutdown(); err != nil {
+		t.Errorf("Expected err to be nil, got: %v", err)
+	}
+	if err := stop(); err != nil || server.stops != 1 {
+		t.Errorf("Expected exports not to be stopped again, got %d stops and err %v", server.stops, err)
+	}
+	if len(events) != len(expected) {
+		t.Errorf("Expected shutdown to run once, got %v", events)
+	}
+	if _, err := wp.RPCServer().Serve("wasi:keyvalue/store", "set", nil); err == nil {
+		t.Error("Expected serving exports after shutdown to fail")
+	}
+}
+
+func TestShutdownErrors(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	wp := newTestProvider(t,
+		ShutdownTimeout(100*time.Millisecond),
+		Shutdown(func() error {
+			<-release
+			return nil
+		}),
+	)
+	wp.internalShutdownFuncs = append(wp.internalShutdownFuncs, func(context.Context) error {
+		return errors.New("exporter unreachable")
+	})
+	server := &fakeServer{}
+	wp.rpcServer = newRPCServer(server, wp.Logger)
+
+	if _, err := wp.RPCServer().Serve("wasi:keyvalue/store", "get", func(context.Context, wrpc.IndexWriteCloser, wrpc.IndexReadCloser) {
+		<-release
+	}); err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	go server.invoke("wasi:keyvalue/store.get")
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	err := wp.Shutdown()
+	if err == nil {
+		t.Fatal("Expected shutdown to fail")
+	}
+	// The timeout applies to the whole shutdown, not to each phase
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("Expected shutdown to complete within the timeout, took %s", elapsed)
+	}
+	for _, s := range []string{"1 in-flight invocation(s) didn't complete", "provider shutdown function failed", "exporter unreachable"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q, got: %v", s, err)
+		}
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error to wrap the deadline, got: %v", err)
+	}
+	if !wp.NatsConnection().IsClosed() {
+		t.Error("Expected lattice connection to be closed despite the errors")
+	}
+	if wp.Shutdown() != err {
+		t.Error("Expected later shutdowns to return the same error")
+	}
+}
+
+func TestLatticeShutdown(t *testing.T) {
+	var hooks int
+	wp := newTestProvider(t, Shutdown(func() error {
+		hooks++
+		return nil
+	}))
+	// The host is answered once the shutdown hook returned, without waiting for telemetry
+	answered := make(chan struct{})
+	wp.internalShutdownFuncs = append(wp.internalShutdownFuncs, func(ctx context.Context) error {
+		select {
+		case <-answered:
+		case <-ctx.Done():
+			t.Error("Expected the host to be answered before telemetry is flushed")
+		}
+		return nil
+	})
+
+	exited := make(chan runResult, 1)
+	go func() {
+		reason, err := wp.StartContext(context.Background())
+		exited <- runResult{reason, err}
+	}()
+
+	// Send the request from another connection, as the provider's own is closed
+	nc, err := nats.Connect(wp.NatsConnection().ConnectedUrl())
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got: %v", err)
+	}
+	defer nc.Close()
+
+	var msg *nats.Msg
+	for range 50 {
+		msg, err = nc.Request(wp.Topics.LATTICE_SHUTDOWN, nil, 5*time.Second)
+		if !errors.Is(err, nats.ErrNoResponders) {
+			break
+		}
+		// Wait for Start to subscribe
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected shutdown to be acknowledged, got: %v", err)
+	}
+	close(answered)
+	if string(msg.Data) != "provider shutdown handled successfully" {
+		t.Errorf("Unexpected shutdown response: %s", msg.Data)
+	}
+
+	if res := waitForExit(t, exited); res.reason != ExitReasonHostShutdown || res.err != nil {
+		t.Errorf("Expected to exit because of the host shutdown, got %q and err %v", res.reason, res.err)
+	}
+
+	if err := wp.Shutdown(); err != nil {
+		t.Errorf("Expected err to be nil, got: %v", err)
+	}
+	if hooks != 1 {
+		t.Errorf("Expected the shutdown hook to be called once, got %d", hooks)
+	}
+	if !wp.NatsConnection().IsClosed() {
+		t.Error("Expected lattice connection to be closed")
+	}
+}
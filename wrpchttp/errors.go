@@ -0,0 +1,408 @@
+package wrpchttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
+)
+
+// Errors matching the variants of the `wasi:http/types` error-code, with errors.Is.
+var (
+	ErrDnsTimeout                     = errors.New("dns timeout")
+	ErrDnsError                       = errors.New("dns error")
+	ErrDestinationNotFound            = errors.New("destination not found")
+	ErrDestinationUnavailable         = errors.New("destination unavailable")
+	ErrDestinationIpProhibited        = errors.New("destination ip prohibited")
+	ErrDestinationIpUnroutable        = errors.New("destination ip unroutable")
+	ErrConnectionRefused              = errors.New("connection refused")
+	ErrConnectionTerminated           = errors.New("connection terminated")
+	ErrConnectionTimeout              = errors.New("connection timeout")
+	ErrConnectionReadTimeout          = errors.New("connection read timeout")
+	ErrConnectionWriteTimeout         = errors.New("connection write timeout")
+	ErrConnectionLimitReached         = errors.New("connection limit reached")
+	ErrTlsProtocolError               = errors.New("tls protocol error")
+	ErrTlsCertificateError            = errors.New("tls certificate error")
+	ErrTlsAlertReceived               = errors.New("tls alert received")
+	ErrHttpRequestDenied              = errors.New("http request denied")
+	ErrHttpRequestLengthRequired      = errors.New("http request length required")
+	ErrHttpRequestBodySize            = errors.New("http request body size")
+	ErrHttpRequestMethodInvalid       = errors.New("http request method invalid")
+	ErrHttpRequestUriInvalid          = errors.New("http request uri invalid")
+	ErrHttpRequestUriTooLong          = errors.New("http request uri too long")
+	ErrHttpRequestHeaderSectionSize   = errors.New("http request header section size")
+	ErrHttpRequestHeaderSize          = errors.New("http request header size")
+	ErrHttpRequestTrailerSectionSize  = errors.New("http request trailer section size")
+	ErrHttpRequestTrailerSize         = errors.New("http request trailer size")
+	ErrHttpResponseIncomplete         = errors.New("http response incomplete")
+	ErrHttpResponseHeaderSectionSize  = errors.New("http response header section size")
+	ErrHttpResponseHeaderSize         = errors.New("http response header size")
+	ErrHttpResponseBodySize           = errors.New("http response body size")
+	ErrHttpResponseTrailerSectionSize = errors.New("http response trailer section size")
+	ErrHttpResponseTrailerSize        = errors.New("http response trailer size")
+	ErrHttpResponseTransferCoding     = errors.New("http response transfer coding")
+	ErrHttpResponseContentCoding      = errors.New("http response content coding")
+	ErrHttpResponseTimeout            = errors.New("http response timeout")
+	ErrHttpUpgradeFailed              = errors.New("http upgrade failed")
+	ErrHttpProtocolError              = errors.New("http protocol error")
+	ErrLoopDetected                   = errors.New("loop detected")
+	ErrConfigurationError             = errors.New("configuration error")
+	ErrInternalError                  = errors.New("internal error")
+)
+
+// errorCodes maps each error-code discriminant to its error, and to a constructor of the
+// error-code without payload.
+var errorCodes = [...]struct {
+	err  error
+	code func() *wrpctypes.ErrorCode
+}{
+	wasitypes.ErrorCodeDnsTimeout: {ErrDnsTimeout, wasitypes.NewErrorCodeDnsTimeout},
+	wasitypes.ErrorCodeDnsError: {ErrDnsError, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeDnsError(&wasitypes.DnsErrorPayload{})
+	}},
+	wasitypes.ErrorCodeDestinationNotFound:     {ErrDestinationNotFound, wasitypes.NewErrorCodeDestinationNotFound},
+	wasitypes.ErrorCodeDestinationUnavailable:  {ErrDestinationUnavailable, wasitypes.NewErrorCodeDestinationUnavailable},
+	wasitypes.ErrorCodeDestinationIpProhibited: {ErrDestinationIpProhibited, wasitypes.NewErrorCodeDestinationIpProhibited},
+	wasitypes.ErrorCodeDestinationIpUnroutable: {ErrDestinationIpUnroutable, wasitypes.NewErrorCodeDestinationIpUnroutable},
+	wasitypes.ErrorCodeConnectionRefused:       {ErrConnectionRefused, wasitypes.NewErrorCodeConnectionRefused},
+	wasitypes.ErrorCodeConnectionTerminated:    {ErrConnectionTerminated, wasitypes.NewErrorCodeConnectionTerminated},
+	wasitypes.ErrorCodeConnectionTimeout:       {ErrConnectionTimeout, wasitypes.NewErrorCodeConnectionTimeout},
+	wasitypes.ErrorCodeConnectionReadTimeout:   {ErrConnectionReadTimeout, wasitypes.NewErrorCodeConnectionReadTimeout},
+	wasitypes.ErrorCodeConnectionWriteTimeout:  {ErrConnectionWriteTimeout, wasitypes.NewErrorCodeConnectionWriteTimeout},
+	wasitypes.ErrorCodeConnectionLimitReached:  {ErrConnectionLimitReached, wasitypes.NewErrorCodeConnectionLimitReached},
+	wasitypes.ErrorCodeTlsProtocolError:        {ErrTlsProtocolError, wasitypes.NewErrorCodeTlsProtocolError},
+	wasitypes.ErrorCodeTlsCertificateError:     {ErrTlsCertificateError, wasitypes.NewErrorCodeTlsCertificateError},
+	wasitypes.ErrorCodeTlsAlertReceived: {ErrTlsAlertReceived, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeTlsAlertReceived(&wasitypes.TlsAlertReceivedPayload{})
+	}},
+	wasitypes.ErrorCodeHttpRequestDenied:         {ErrHttpRequestDenied, wasitypes.NewErrorCodeHttpRequestDenied},
+	wasitypes.ErrorCodeHttpRequestLengthRequired: {ErrHttpRequestLengthRequired, wasitypes.NewErrorCodeHttpRequestLengthRequired},
+	wasitypes.ErrorCodeHttpRequestBodySize: {ErrHttpRequestBodySize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpRequestBodySize(nil)
+	}},
+	wasitypes.ErrorCodeHttpRequestMethodInvalid: {ErrHttpRequestMethodInvalid, wasitypes.NewErrorCodeHttpRequestMethodInvalid},
+	wasitypes.ErrorCodeHttpRequestUriInvalid:    {ErrHttpRequestUriInvalid, wasitypes.NewErrorCodeHttpRequestUriInvalid},
+	wasitypes.ErrorCodeHttpRequestUriTooLong:    {ErrHttpRequestUriTooLong, wasitypes.NewErrorCodeHttpRequestUriTooLong},
+	wasitypes.ErrorCodeHttpRequestHeaderSectionSize: {ErrHttpRequestHeaderSectionSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpRequestHeaderSectionSize(nil)
+	}},
+	wasitypes.ErrorCodeHttpRequestHeaderSize: {ErrHttpRequestHeaderSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpRequestHeaderSize(nil)
+	}},
+	wasitypes.ErrorCodeHttpRequestTrailerSectionSize: {ErrHttpRequestTrailerSectionSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpRequestTrailerSectionSize(nil)
+	}},
+	wasitypes.ErrorCodeHttpRequestTrailerSize: {ErrHttpRequestTrailerSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpRequestTrailerSize(&wasitypes.FieldSizePayload{})
+	}},
+	wasitypes.ErrorCodeHttpResponseIncomplete: {ErrHttpResponseIncomplete, wasitypes.NewErrorCodeHttpResponseIncomplete},
+	wasitypes.ErrorCodeHttpResponseHeaderSectionSize: {ErrHttpResponseHeaderSectionSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseHeaderSectionSize(nil)
+	}},
+	wasitypes.ErrorCodeHttpResponseHeaderSize: {ErrHttpResponseHeaderSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseHeaderSize(&wasitypes.FieldSizePayload{})
+	}},
+	wasitypes.ErrorCodeHttpResponseBodySize: {ErrHttpResponseBodySize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseBodySize(nil)
+	}},
+	wasitypes.ErrorCodeHttpResponseTrailerSectionSize: {ErrHttpResponseTrailerSectionSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseTrailerSectionSize(nil)
+	}},
+	wasitypes.ErrorCodeHttpResponseTrailerSize: {ErrHttpResponseTrailerSize, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseTrailerSize(&wasitypes.FieldSizePayload{})
+	}},
+	wasitypes.ErrorCodeHttpResponseTransferCoding: {ErrHttpResponseTransferCoding, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseTransferCoding(nil)
+	}},
+	wasitypes.ErrorCodeHttpResponseContentCoding: {ErrHttpResponseContentCoding, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeHttpResponseContentCoding(nil)
+	}},
+	wasitypes.ErrorCodeHttpResponseTimeout: {ErrHttpResponseTimeout, wasitypes.NewErrorCodeHttpResponseTimeout},
+	wasitypes.ErrorCodeHttpUpgradeFailed:   {ErrHttpUpgradeFailed, wasitypes.NewErrorCodeHttpUpgradeFailed},
+	wasitypes.ErrorCodeHttpProtocolError:   {ErrHttpProtocolError, wasitypes.NewErrorCodeHttpProtocolError},
+	wasitypes.ErrorCodeLoopDetected:        {ErrLoopDetected, wasitypes.NewErrorCodeLoopDetected},
+	wasitypes.ErrorCodeConfigurationError:  {ErrConfigurationError, wasitypes.NewErrorCodeConfigurationError},
+	wasitypes.ErrorCodeInternalError: {ErrInternalError, func() *wrpctypes.ErrorCode {
+		return wasitypes.NewErrorCodeInternalError(nil)
+	}},
+}
+
+// HttpError is an error-code returned by a component or provider. It matches the Err variable
+// of its variant with errors.Is, and when relevant context.DeadlineExceeded, a *net.DNSError,
+// a syscall.Errno or a *http.MaxBytesError.
+type HttpError struct {
+	code *wrpctypes.ErrorCode
+}
+
+var _ net.Error = (*HttpError)(nil)
+
+// ErrorCodeToError converts an error-code to an *HttpError, or returns nil if code is nil.
+func ErrorCodeToError(code *wrpctypes.ErrorCode) error {
+	if code == nil {
+		return nil
+	}
+	return &HttpError{code: code}
+}
+
+// Code returns the error-code, for instance to forward it.
+func (e *HttpError) Code() *wrpctypes.ErrorCode {
+	return e.code
+}
+
+func (e *HttpError) Error() string {
+	msg := e.sentinel().Error()
+	if detail := e.detail(); detail != "" {
+		msg += ": " + detail
+	}
+	return msg
+}
+
+func (e *HttpError) Unwrap() []error {
+	errs := []error{e.sentinel()}
+	if e.Timeout() {
+		errs = append(errs, context.DeadlineExceeded)
+	}
+	switch e.code.Discriminant() {
+	case wasitypes.ErrorCodeDnsTimeout:
+		errs = append(errs, &net.DNSError{Err: "timeout", IsTimeout: true})
+	case wasitypes.ErrorCodeDnsError:
+		rcode := "dns error"
+		if payload, ok := e.code.GetDnsError(); ok && payload.Rcode != nil {
+			rcode = *payload.Rcode
+		}
+		errs = append(errs, &net.DNSError{Err: rcode})
+	case wasitypes.ErrorCodeDestinationNotFound:
+		errs = append(errs, &net.DNSError{Err: "no such host", IsNotFound: true})
+	case wasitypes.ErrorCodeDestinationUnavailable:
+		errs = append(errs, syscall.EHOSTUNREACH)
+	case wasitypes.ErrorCodeDestinationIpUnroutable:
+		errs = append(errs, syscall.ENETUNREACH)
+	case wasitypes.ErrorCodeConnectionRefused:
+		errs = append(errs, syscall.ECONNREFUSED)
+	case wasitypes.ErrorCodeConnectionTerminated:
+		errs = append(errs, syscall.ECONNRESET)
+	case wasitypes.ErrorCodeHttpRequestBodySize:
+		if size, _ := e.code.GetHttpRequestBodySize(); size != nil {
+			errs = append(errs, &http.MaxBytesError{Limit: int64(*size)})
+		}
+	}
+	return errs
+}
+
+// Timeout reports whether the error-code is a timeout, implementing net.Error.
+func (e *HttpError) Timeout() bool {
+	switch e.code.Discriminant() {
+	case wasitypes.ErrorCodeDnsTimeout,
+		wasitypes.ErrorCodeConnectionTimeout,
+		wasitypes.ErrorCodeConnectionReadTimeout,
+		wasitypes.ErrorCodeConnectionWriteTimeout,
+		wasitypes.ErrorCodeHttpResponseTimeout:
+		return true
+	}
+	return false
+}
+
+// Temporary reports whether the request may succeed if retried.
+//
+// Deprecated: as for net.Error, use Timeout or errors.Is instead.
+func (e *HttpError) Temporary() bool {
+	switch e.code.Discriminant() {
+	case wasitypes.ErrorCodeConnectionRefused,
+		wasitypes.ErrorCodeConnectionTerminated,
+		wasitypes.ErrorCodeConnectionLimitReached:
+		return true
+	}
+	return e.Timeout()
+}
+
+func (e *HttpError) sentinel() error {
+	if d := int(e.code.Discriminant()); d < len(errorCodes) {
+		return errorCodes[d].err
+	}
+	return fmt.Errorf("unknown error-code %d", e.code.Discriminant())
+}
+
+// detail formats the payload of the error-code. The generated getters of record payloads only
+// return them when set by value, so they may be missing.
+func (e *HttpError) detail() string {
+	switch e.code.Discriminant() {
+	case wasitypes.ErrorCodeDnsError:
+		if payload, ok := e.code.GetDnsError(); ok {
+			return formatPayload("rcode", payload.Rcode, "info code", payload.InfoCode)
+		}
+	case wasitypes.ErrorCodeTlsAlertReceived:
+		if payload, ok := e.code.GetTlsAlertReceived(); ok {
+			return formatPayload("alert", payload.AlertMessage, "id", payload.AlertId)
+		}
+	case wasitypes.ErrorCodeHttpRequestBodySize:
+		size, _ := e.code.GetHttpRequestBodySize()
+		return formatPayload("size", size)
+	case wasitypes.ErrorCodeHttpRequestHeaderSectionSize:
+		size, _ := e.code.GetHttpRequestHeaderSectionSize()
+		return formatPayload("size", size)
+	case wasitypes.ErrorCodeHttpRequestHeaderSize:
+		if payload, _ := e.code.GetHttpRequestHeaderSize(); payload != nil {
+			return formatPayload("field", payload.FieldName, "size", payload.FieldSize)
+		}
+	case wasitypes.ErrorCodeHttpRequestTrailerSectionSize:
+		size, _ := e.code.GetHttpRequestTrailerSectionSize()
+		return formatPayload("size", size)
+	case wasitypes.ErrorCodeHttpRequestTrailerSize:
+		if payload, ok := e.code.GetHttpRequestTrailerSize(); ok {
+			return formatPayload("field", payload.FieldName, "size", payload.FieldSize)
+		}
+	case wasitypes.ErrorCodeHttpResponseHeaderSectionSize:
+		size, _ := e.code.GetHttpResponseHeaderSectionSize()
+		return formatPayload("size", size)
+	case wasitypes.ErrorCodeHttpResponseHeaderSize:
+		if payload, ok := e.code.GetHttpResponseHeaderSize(); ok {
+			return formatPayload("field", payload.FieldName, "size", payload.FieldSize)
+		}
+	case wasitypes.ErrorCodeHttpResponseBodySize:
+		size, _ := e.code.GetHttpResponseBodySize()
+		return formatPayload("size", size)
+	case wasitypes.ErrorCodeHttpResponseTrailerSectionSize:
+		size, _ := e.code.GetHttpResponseTrailerSectionSize()
+		return formatPayload("size", size)
+	case wasitypes.ErrorCodeHttpResponseTrailerSize:
+		if payload, ok := e.code.GetHttpResponseTrailerSize(); ok {
+			return formatPayload("field", payload.FieldName, "size", payload.FieldSize)
+		}
+	case wasitypes.ErrorCodeHttpResponseTransferCoding:
+		coding, _ := e.code.GetHttpResponseTransferCoding()
+		return formatPayload("coding", coding)
+	case wasitypes.ErrorCodeHttpResponseContentCoding:
+		coding, _ := e.code.GetHttpResponseContentCoding()
+		return formatPayload("coding", coding)
+	case wasitypes.ErrorCodeInternalError:
+		if msg, _ := e.code.GetInternalError(); msg != nil {
+			return *msg
+		}
+	}
+	return ""
+}
+
+// formatPayload formats pairs of names and optional values, skipping the nil ones.
+func formatPayload(pairs ...any) string {
+	var msg string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		var value any
+		switch v := pairs[i+1].(type) {
+		case *string:
+			if v != nil {
+				value = *v
+			}
+		case *uint8:
+			if v != nil {
+				value = *v
+			}
+		case *uint16:
+			if v != nil {
+				value = *v
+			}
+		case *uint32:
+			if v != nil {
+				value = *v
+			}
+		case *uint64:
+			if v != nil {
+				value = *v
+			}
+		}
+		if value == nil {
+			continue
+		}
+		if msg != "" {
+			msg += ", "
+		}
+		msg += fmt.Sprintf("%s %v", pairs[i], value)
+	}
+	return msg
+}
+
+// ErrorCodeFromError maps an error to the error-code returned to a caller. Handlers can return
+// an *HttpError or wrap one of the Err variables of the variants, network, TLS and timeout
+// errors are mapped to the closest variant, anything else is an internal-error. A nil error
+// maps to nil.
+func ErrorCodeFromError(err error) *wrpctypes.ErrorCode {
+	if err == nil {
+		return nil
+	}
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr.code
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code()
+		}
+	}
+
+	var (
+		dnsErr         *net.DNSError
+		opErr          *net.OpError
+		alertErr       tls.AlertError
+		recordErr      tls.RecordHeaderError
+		verifyErr      *tls.CertificateVerificationError
+		authorityErr   x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certInvalidErr x509.CertificateInvalidError
+		maxBytesErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.Is(err, errConnectTimeout):
+		return wasitypes.NewErrorCodeConnectionTimeout()
+	case errors.Is(err, errFirstByteTimeout), errors.Is(err, errBetweenBytesTimeout):
+		return wasitypes.NewErrorCodeConnectionReadTimeout()
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return wasitypes.NewErrorCodeDnsTimeout()
+		}
+		if dnsErr.IsNotFound {
+			return wasitypes.NewErrorCodeDestinationNotFound()
+		}
+		rcode := dnsErr.Err
+		return wasitypes.NewErrorCodeDnsError(&wasitypes.DnsErrorPayload{Rcode: &rcode})
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return wasitypes.NewErrorCodeConnectionRefused()
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return wasitypes.NewErrorCodeConnectionTerminated()
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return wasitypes.NewErrorCodeDestinationUnavailable()
+	case errors.Is(err, syscall.ENETUNREACH):
+		return wasitypes.NewErrorCodeDestinationIpUnroutable()
+	case errors.As(err, &alertErr):
+		id := uint8(alertErr)
+		msg := alertErr.Error()
+		return wasitypes.NewErrorCodeTlsAlertReceived(&wasitypes.TlsAlertReceivedPayload{AlertId: &id, AlertMessage: &msg})
+	case errors.As(err, &recordErr):
+		return wasitypes.NewErrorCodeTlsProtocolError()
+	case errors.As(err, &verifyErr), errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr):
+		return wasitypes.NewErrorCodeTlsCertificateError()
+	case errors.As(err, &maxBytesErr):
+		limit := uint64(maxBytesErr.Limit)
+		return wasitypes.NewErrorCodeHttpRequestBodySize(&limit)
+	case errors.As(err, &opErr) && opErr.Timeout():
+		switch opErr.Op {
+		case "read":
+			return wasitypes.NewErrorCodeConnectionReadTimeout()
+		case "write":
+			return wasitypes.NewErrorCodeConnectionWriteTimeout()
+		}
+		return wasitypes.NewErrorCodeConnectionTimeout()
+	case errors.Is(err, context.DeadlineExceeded):
+		return wasitypes.NewErrorCodeHttpResponseTimeout()
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return wasitypes.NewErrorCodeHttpResponseIncomplete()
+	}
+	msg := err.Error()
+	return wasitypes.NewErrorCodeInternalError(&msg)
+}
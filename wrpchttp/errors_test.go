@@ -0,0 +1,154 @@
+package wrpchttp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+	"go.wasmcloud.dev/provider/internal/wrpc/http/incoming_handler"
+	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
+	wrpc "wrpc.io/go"
+	wrpcnats "wrpc.io/go/nats"
+)
+
+func TestErrorCodeToError(t *testing.T) {
+	limit := uint64(1024)
+	tt := map[string]struct {
+		code    *wrpctypes.ErrorCode
+		targets []error
+		timeout bool
+	}{
+		"dns timeout": {
+			code:    wasitypes.NewErrorCodeDnsTimeout(),
+			targets: []error{ErrDnsTimeout, context.DeadlineExceeded},
+			timeout: true,
+		},
+		"destination not found": {
+			code:    wasitypes.NewErrorCodeDestinationNotFound(),
+			targets: []error{ErrDestinationNotFound},
+		},
+		"connection refused": {
+			code:    wasitypes.NewErrorCodeConnectionRefused(),
+			targets: []error{ErrConnectionRefused, syscall.ECONNREFUSED},
+		},
+		"connection read timeout": {
+			code:    wasitypes.NewErrorCodeConnectionReadTimeout(),
+			targets: []error{ErrConnectionReadTimeout, context.DeadlineExceeded},
+			timeout: true,
+		},
+		"request body size": {
+			code:    wasitypes.NewErrorCodeHttpRequestBodySize(&limit),
+			targets: []error{ErrHttpRequestBodySize},
+		},
+		"loop detected": {
+			code:    wasitypes.NewErrorCodeLoopDetected(),
+			targets: []error{ErrLoopDetected},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := ErrorCodeToError(tc.code)
+			for _, target := range tc.targets {
+				if !errors.Is(err, target) {
+					t.Errorf("expected %v to match %v", err, target)
+				}
+			}
+			if errors.Is(err, ErrInternalError) {
+				t.Errorf("expected %v not to match %v", err, ErrInternalError)
+			}
+			var netErr net.Error
+			if !errors.As(err, &netErr) || netErr.Timeout() != tc.timeout {
+				t.Errorf("expected a net.Error with timeout %v, got %v", tc.timeout, err)
+			}
+			if want, got := tc.code.Discriminant(), ErrorCodeFromError(err).Discriminant(); want != got {
+				t.Errorf("expected the error-code to round trip, want %v, got %v", want, got)
+			}
+		})
+	}
+
+	var dnsErr *net.DNSError
+	if err := ErrorCodeToError(wasitypes.NewErrorCodeDestinationNotFound()); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected a not found *net.DNSError, got %v", err)
+	}
+	var maxBytesErr *http.MaxBytesError
+	if err := ErrorCodeToError(wasitypes.NewErrorCodeHttpRequestBodySize(&limit)); !errors.As(err, &maxBytesErr) || maxBytesErr.Limit != 1024 {
+		t.Errorf("expected a *http.MaxBytesError, got %v", err)
+	}
+	msg := "boom"
+	if want, got := "internal error: boom", ErrorCodeToError(wasitypes.NewErrorCodeInternalError(&msg)).Error(); want != got {
+		t.Errorf("expected message %q, got %q", want, got)
+	}
+	if err := ErrorCodeToError(nil); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestErrorCodeFromError(t *testing.T) {
+	tt := map[string]struct {
+		err  error
+		want wasitypes.ErrorCodeDiscriminant
+	}{
+		"sentinel":          {fmt.Errorf("upstream: %w", ErrLoopDetected), wasitypes.ErrorCodeLoopDetected},
+		"dns timeout":       {&net.DNSError{Err: "timeout", IsTimeout: true}, wasitypes.ErrorCodeDnsTimeout},
+		"dns not found":     {&net.DNSError{Err: "no such host", IsNotFound: true}, wasitypes.ErrorCodeDestinationNotFound},
+		"dns error":         {&net.DNSError{Err: "server misbehaving"}, wasitypes.ErrorCodeDnsError},
+		"refused":           {&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, wasitypes.ErrorCodeConnectionRefused},
+		"reset":             {&net.OpError{Op: "read", Err: syscall.ECONNRESET}, wasitypes.ErrorCodeConnectionTerminated},
+		"read timeout":      {&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, wasitypes.ErrorCodeConnectionReadTimeout},
+		"dial timeout":      {&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, wasitypes.ErrorCodeConnectionTimeout},
+		"tls alert":         {tls.AlertError(40), wasitypes.ErrorCodeTlsAlertReceived},
+		"max bytes":         {&http.MaxBytesError{Limit: 10}, wasitypes.ErrorCodeHttpRequestBodySize},
+		"deadline":          {context.DeadlineExceeded, wasitypes.ErrorCodeHttpResponseTimeout},
+		"first byte":        {errFirstByteTimeout, wasitypes.ErrorCodeConnectionReadTimeout},
+		"other":             {errors.New("boom"), wasitypes.ErrorCodeInternalError},
+		"between bytes":     {errBetweenBytesTimeout, wasitypes.ErrorCodeConnectionReadTimeout},
+		"connect timeout":   {errConnectTimeout, wasitypes.ErrorCodeConnectionTimeout},
+		"unreachable host":  {syscall.EHOSTUNREACH, wasitypes.ErrorCodeDestinationUnavailable},
+		"broken connection": {syscall.EPIPE, wasitypes.ErrorCodeConnectionTerminated},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if want, got := tc.want, ErrorCodeFromError(tc.err).Discriminant(); want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+
+	code := wasitypes.NewErrorCodeHttpResponseTimeout()
+	if got := ErrorCodeFromError(fmt.Errorf("wrapped: %w", ErrorCodeToError(code))); got != code {
+		t.Errorf("expected the error-code to be forwarded, got %v", got)
+	}
+	if got := ErrorCodeFromError(nil); got != nil {
+		t.Errorf("expected no error-code, got %v", got)
+	}
+}
+
+func TestRoundtripErrorCode(t *testing.T) {
+	fakeNc := fakeNatsCreator{
+		OutgoingRpcClientFunc: func(string) *wrpcnats.Client { return nil },
+	}
+	roundTripper := NewIncomingRoundTripper(fakeNc, WithSingleTarget("component_id"))
+	roundTripper.invoker = func(_ context.Context, invoker wrpc.Invoker, _ *wrpctypes.Request) (*wrpc.Result[incoming_handler.Response, incoming_handler.ErrorCode], <-chan error, error) {
+		if _, ok := invoker.(*wrpcnats.Client); ok {
+			t.Error("expected the invocation to be instrumented")
+		}
+		errCh := make(chan error)
+		close(errCh)
+		return wrpc.Err[incoming_handler.Response](*wasitypes.NewErrorCodeConnectionRefused()), errCh, nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	_, err := roundTripper.RoundTrip(req)
+	if !errors.Is(err, ErrRPC) || !errors.Is(err, ErrConnectionRefused) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected a connection refused error, got %v", err)
+	}
+}
@@ -2,12 +2,17 @@ package wrpchttp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
-	"sync/atomic"
 
+	"go.wasmcloud.dev/provider"
 	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
 	"go.wasmcloud.dev/provider/internal/wrpc/http/incoming_handler"
 	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
@@ -16,9 +21,9 @@ import (
 )
 
 type IncomingRoundTripper struct {
-	director    func(*http.Request) string
-	natsCreator NatsClientCreator
-	invoker     func(context.Context, wrpc.Invoker, *wrpctypes.Request) (*wrpc.Result[incoming_handler.Response, incoming_handler.ErrorCode], <-chan error, error)
+	director func(*http.Request) string
+	client   func(target string) wrpc.Invoker
+	invoker  func(context.Context, wrpc.Invoker, *wrpctypes.Request) (*wrpc.Result[incoming_handler.Response, incoming_handler.ErrorCode], <-chan error, error)
 }
 
 var _ http.RoundTripper = (*IncomingRoundTripper)(nil)
@@ -39,8 +44,10 @@ func WithSingleTarget(target string) IncomingHandlerOption {
 
 func NewIncomingRoundTripper(nc NatsClientCreator, opts ...IncomingHandlerOption) *IncomingRoundTripper {
 	p := &IncomingRoundTripper{
-		natsCreator: nc,
-		invoker:     incoming_handler.Handle,
+		client: func(target string) wrpc.Invoker {
+			return nc.OutgoingRpcClient(target)
+		},
+		invoker: incoming_handler.Handle,
 	}
 	for _, opt := range opts {
 		opt(p)
@@ -48,6 +55,9 @@ func NewIncomingRoundTripper(nc NatsClientCreator, opts ...IncomingHandlerOption
 	return p
 }
 
+// RoundTrip invokes `wrpc:http/incoming-handler` on the target of the request, returning once
+// the response headers are received. The response body is streamed, closing it or cancelling
+// the context of the request closes the wRPC streams and cancels the invocation.
 func (p *IncomingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	target := p.director(r)
 	if target == "" {
@@ -60,7 +70,7 @@ func (p *IncomingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error
 		pathWithQuery += "?" + r.URL.RawQuery
 	}
 	wreq := &wrpctypes.Request{
-		Headers:       HttpHeaderToWrpc(r.Header),
+		Headers:       HttpHeaderToWrpc(outgoingHeader(r.Header, r.Trailer, r.ContentLength)),
 		Method:        HttpMethodToWrpc(r.Method),
 		Scheme:        HttpSchemeToWrpc(r.URL.Scheme),
 		PathWithQuery: &pathWithQuery,
@@ -69,60 +79,130 @@ func (p *IncomingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error
 		Trailers:      outgoingBodyTrailer,
 	}
 
-	wrpcClient := p.natsCreator.OutgoingRpcClient(target)
-	wresp, errCh, err := p.invoker(r.Context(), wrpcClient, wreq)
+	// The invocation is cancelled once the response body is closed
+	ctx, cancel := context.WithCancel(r.Context())
+	// Trace the invocation and send its trace context to the component
+	wrpcClient := provider.InstrumentInvoker(p.client(target))
+	wresp, errCh, err := p.invoker(ctx, wrpcClient, wreq)
 	if err != nil {
+		cancel()
+		outgoingBodyTrailer.Close()
 		return nil, err
 	}
 
 	if wresp.Err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrRPC, wresp.Err)
+		cancel()
+		outgoingBodyTrailer.Close()
+		drainWriteErrors(errCh, nil)
+		return nil, fmt.Errorf("%w: %w", ErrRPC, ErrorCodeToError(wresp.Err))
 	}
 
 	respBody, trailers := WrpcBodyToHttp(wresp.Ok.Body, wresp.Ok.Trailers)
+	respBody.onClose = func() {
+		cancel()
+		outgoingBodyTrailer.Close()
+	}
+	context.AfterFunc(ctx, func() {
+		respBody.closeWithError(context.Cause(ctx))
+	})
+	// Failures to send the request body abort the response body
+	drainWriteErrors(errCh, func(err error) {
+		respBody.closeWithError(fmt.Errorf("%w: %w", ErrRPC, err))
+	})
 
 	resp := &http.Response{
-		StatusCode: int(wresp.Ok.Status),
-		Header:     make(http.Header),
-		Request:    r,
-		Body:       respBody,
-		Trailer:    trailers,
+		Status:        fmt.Sprintf("%d %s", wresp.Ok.Status, http.StatusText(int(wresp.Ok.Status))),
+		StatusCode:    int(wresp.Ok.Status),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        incomingHeader(wresp.Ok.Headers, trailers),
+		Request:       r,
+		Body:          respBody,
+		ContentLength: -1,
+		Trailer:       trailers,
 	}
-
-	for _, hdr := range wresp.Ok.Headers {
-		for _, hdrVal := range hdr.V1 {
-			resp.Header.Add(hdr.V0, string(hdrVal))
-		}
+	if contentLength, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil && contentLength >= 0 {
+		resp.ContentLength = contentLength
 	}
 
-	errList := []error{}
-	for err := range errCh {
-		errList = append(errList, err)
-	}
+	return resp, nil
+}
 
-	if len(errList) > 0 {
-		return nil, fmt.Errorf("%w: %v", ErrRPC, errList)
+// drainWriteErrors receives the errors of the writes of an invocation in the background, until
+// they complete.
+func drainWriteErrors(errCh <-chan error, handle func(error)) {
+	if errCh == nil {
+		return
 	}
-
-	return resp, nil
+	go func() {
+		for err := range errCh {
+			if handle != nil {
+				handle(err)
+			}
+		}
+	}()
 }
 
+var errBodyClosed = errors.New("read on closed body")
+
+// wrpcIncomingBody reads a wRPC byte stream, receiving the trailers once it's read to the end.
+// The stream is closed once read to the end, closed, or aborted with closeWithError.
 type wrpcIncomingBody struct {
-	body           io.Reader
-	trailer        http.Header
-	trailerRx      wrpc.Receiver[[]*wrpc.Tuple2[string, [][]byte]]
-	trailerOnce    sync.Once
-	trailerIsReady uint32
+	body        io.Reader
+	trailer     http.Header
+	trailerRx   wrpc.Receiver[[]*wrpc.Tuple2[string, [][]byte]]
+	trailerOnce sync.Once
+	trailerErr  error
+
+	closeOnce sync.Once
+	closeErr  error
+	lock      sync.Mutex
+	err       error
+	// onClose is called once the body is closed
+	onClose func()
 }
 
 func (r *wrpcIncomingBody) Close() error {
-	return nil
+	return r.closeWithError(errBodyClosed)
 }
 
-func (r *wrpcIncomingBody) readTrailerOnce() {
+// closeWithError closes the stream, subsequent reads returning err.
+func (r *wrpcIncomingBody) closeWithError(err error) error {
+	r.closeOnce.Do(func() {
+		// Set before closing the stream so that pending reads return err
+		r.lock.Lock()
+		r.err = err
+		r.lock.Unlock()
+
+		if closer, ok := r.body.(io.Closer); ok {
+			r.closeErr = closer.Close()
+		}
+		if r.trailerRx != nil {
+			_ = r.trailerRx.Close()
+		}
+		if r.onClose != nil {
+			r.onClose()
+		}
+	})
+	return r.closeErr
+}
+
+func (r *wrpcIncomingBody) loadErr() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// readTrailerOnce fills the trailers once the body is read to the end.
+func (r *wrpcIncomingBody) readTrailerOnce() error {
 	r.trailerOnce.Do(func() {
+		if r.trailerRx == nil {
+			return
+		}
 		trailers, err := r.trailerRx.Receive()
 		if err != nil {
+			r.trailerErr = fmt.Errorf("%w: failed to receive trailers: %w", ErrRPC, err)
 			return
 		}
 		for _, header := range trailers {
@@ -130,14 +210,26 @@ func (r *wrpcIncomingBody) readTrailerOnce() {
 				r.trailer.Add(header.V0, string(value))
 			}
 		}
-		atomic.CompareAndSwapUint32(&r.trailerIsReady, 0, 1)
 	})
+	return r.trailerErr
 }
 
 func (r *wrpcIncomingBody) Read(b []byte) (int, error) {
+	if err := r.loadErr(); err != nil {
+		return 0, err
+	}
 	n, err := r.body.Read(b)
-	if err == io.EOF {
-		r.readTrailerOnce()
+	switch {
+	case err == io.EOF:
+		// The end of the body is only reported once the trailers are received
+		if trailerErr := r.readTrailerOnce(); trailerErr != nil {
+			err = trailerErr
+		}
+		r.closeWithError(err)
+	case err != nil:
+		if closeErr := r.loadErr(); closeErr != nil {
+			err = closeErr
+		}
 	}
 	return n, err
 }
@@ -177,6 +269,9 @@ func (r *wrpcOutgoingBody) Close() error {
 }
 
 func HttpBodyToWrpc(body io.ReadCloser, trailer http.Header) *wrpcOutgoingBody {
+	if body == nil {
+		body = http.NoBody
+	}
 	return &wrpcOutgoingBody{
 		body:       body,
 		trailer:    trailer,
@@ -218,6 +313,36 @@ func HttpMethodToWrpc(method string) *wrpctypes.Method {
 	}
 }
 
+// WrpcMethodToHttp returns the HTTP method of a wRPC method, or an empty string if it's invalid.
+func WrpcMethodToHttp(method *wrpctypes.Method) string {
+	if method == nil {
+		return ""
+	}
+	switch method.Discriminant() {
+	case wasitypes.MethodConnect:
+		return http.MethodConnect
+	case wasitypes.MethodGet:
+		return http.MethodGet
+	case wasitypes.MethodHead:
+		return http.MethodHead
+	case wasitypes.MethodPost:
+		return http.MethodPost
+	case wasitypes.MethodPut:
+		return http.MethodPut
+	case wasitypes.MethodPatch:
+		return http.MethodPatch
+	case wasitypes.MethodDelete:
+		return http.MethodDelete
+	case wasitypes.MethodOptions:
+		return http.MethodOptions
+	case wasitypes.MethodTrace:
+		return http.MethodTrace
+	default:
+		other, _ := method.GetOther()
+		return other
+	}
+}
+
 func HttpSchemeToWrpc(scheme string) *wrpctypes.Scheme {
 	switch scheme {
 	case "http":
@@ -229,6 +354,18 @@ func HttpSchemeToWrpc(scheme string) *wrpctypes.Scheme {
 	}
 }
 
+func WrpcSchemeToHttp(scheme *wrpctypes.Scheme) string {
+	switch scheme.Discriminant() {
+	case wasitypes.SchemeHttp:
+		return "http"
+	case wasitypes.SchemeHttps:
+		return "https"
+	default:
+		other, _ := scheme.GetOther()
+		return other
+	}
+}
+
 func HttpHeaderToWrpc(header http.Header) []*wrpc.Tuple2[string, [][]uint8] {
 	wasiHeader := make([]*wrpc.Tuple2[string, [][]uint8], 0, len(header))
 	for k, vals := range header {
@@ -244,3 +381,87 @@ func HttpHeaderToWrpc(header http.Header) []*wrpc.Tuple2[string, [][]uint8] {
 
 	return wasiHeader
 }
+
+func WrpcHeaderToHttp(fields []*wrpc.Tuple2[string, [][]uint8]) http.Header {
+	header := make(http.Header, len(fields))
+	for _, field := range fields {
+		for _, value := range field.V1 {
+			header.Add(field.V0, string(value))
+		}
+	}
+	return header
+}
+
+// hopByHopHeaders are the connection specific headers, which aren't forwarded.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHopHeaders removes the connection specific headers of h, including the ones listed
+// in its Connection header.
+func removeHopByHopHeaders(h http.Header) {
+	for _, field := range h.Values("Connection") {
+		for _, key := range strings.Split(field, ",") {
+			if key = textproto.TrimString(key); key != "" {
+				h.Del(key)
+			}
+		}
+	}
+	for _, key := range hopByHopHeaders {
+		h.Del(key)
+	}
+}
+
+// outgoingHeader returns the headers of a request or response sent over wRPC. Hop-by-hop
+// headers and Host are removed, the length of the body and the keys of trailer, if any, are
+// set. Otherwise the Trailer header is kept as is.
+func outgoingHeader(header http.Header, trailer http.Header, contentLength int64) http.Header {
+	h := header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	removeHopByHopHeaders(h)
+	h.Del("Host")
+	for key := range h {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			delete(h, key)
+		}
+	}
+	if contentLength > 0 {
+		h.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+	if len(trailer) > 0 {
+		keys := make([]string, 0, len(trailer))
+		for key := range trailer {
+			keys = append(keys, http.CanonicalHeaderKey(key))
+		}
+		sort.Strings(keys)
+		h.Set("Trailer", strings.Join(keys, ", "))
+	}
+	return h
+}
+
+// incomingHeader returns the headers of a request or response received over wRPC. Hop-by-hop
+// headers are removed, and the trailers announced with the Trailer header are added to trailer
+// without values, as net/http does.
+func incomingHeader(fields []*wrpc.Tuple2[string, [][]uint8], trailer http.Header) http.Header {
+	h := WrpcHeaderToHttp(fields)
+	removeHopByHopHeaders(h)
+	for _, declared := range h.Values("Trailer") {
+		for _, key := range strings.Split(declared, ",") {
+			key = http.CanonicalHeaderKey(textproto.TrimString(key))
+			if _, ok := trailer[key]; key != "" && !ok {
+				trailer[key] = nil
+			}
+		}
+	}
+	h.Del("Trailer")
+	return h
+}
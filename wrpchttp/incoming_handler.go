@@ -0,0 +1,245 @@
+package wrpchttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	exports_incoming_handler "go.wasmcloud.dev/provider/internal/exports/wrpc/http/incoming_handler"
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
+
+	wrpc "wrpc.io/go"
+)
+
+// IncomingHandler serves `wrpc:http/incoming-handler` with an http.Handler, so components and
+// other providers can call a Go HTTP application through the lattice.
+type IncomingHandler struct {
+	handler http.Handler
+}
+
+var _ exports_incoming_handler.Handler = (*IncomingHandler)(nil)
+
+func NewIncomingHandler(handler http.Handler) *IncomingHandler {
+	return &IncomingHandler{handler: handler}
+}
+
+// Serve serves `wrpc:http/incoming-handler` on s, usually the provider's RPCServer. It can be
+// passed to provider.Run. The returned function stops serving.
+func (h *IncomingHandler) Serve(s wrpc.Server) (func() error, error) {
+	return exports_incoming_handler.ServeInterface(s, h)
+}
+
+// Handle runs the http.Handler for a request, returning once the response headers are written.
+// The response body and trailers are streamed while the handler runs. The context of the
+// request is cancelled when the invocation is, or when the caller stops reading the response.
+// A handler panicking with an error before writing the headers returns the error-code mapped by
+// ErrorCodeFromError, so panic(ErrLoopDetected) returns loop-detected.
+func (h *IncomingHandler) Handle(ctx context.Context, wreq *wrpctypes.Request) (*wrpc.Result[exports_incoming_handler.Response, exports_incoming_handler.ErrorCode], error) {
+	req, errCode := wrpcRequestToIncomingHttp(wreq)
+	if errCode != nil {
+		return wrpc.Err[exports_incoming_handler.Response](*errCode), nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := newIncomingResponseWriter(cancel)
+	// Unblock the handler if the response is abandoned
+	context.AfterFunc(ctx, func() {
+		w.body.CloseWithError(context.Cause(ctx))
+	})
+	go w.serve(h.handler, req.WithContext(ctx))
+
+	<-w.headerSent
+	if w.headerErr != nil {
+		return wrpc.Err[exports_incoming_handler.Response](*ErrorCodeFromError(w.headerErr)), nil
+	}
+
+	return wrpc.Ok[exports_incoming_handler.ErrorCode](exports_incoming_handler.Response{
+		Status:   uint16(w.status),
+		Headers:  HttpHeaderToWrpc(outgoingHeader(w.sentHeader, nil, -1)),
+		Body:     w.body,
+		Trailers: incomingResponseTrailers{w},
+	}), nil
+}
+
+// wrpcRequestToIncomingHttp converts the request of a caller to a server http.Request.
+func wrpcRequestToIncomingHttp(wreq *wrpctypes.Request) (*http.Request, *wrpctypes.ErrorCode) {
+	method := WrpcMethodToHttp(wreq.Method)
+	if method == "" {
+		return nil, wasitypes.NewErrorCodeHttpRequestMethodInvalid()
+	}
+	requestURI := "/"
+	if wreq.PathWithQuery != nil && *wreq.PathWithQuery != "" {
+		requestURI = *wreq.PathWithQuery
+	}
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return nil, wasitypes.NewErrorCodeHttpRequestUriInvalid()
+	}
+
+	body, trailer := WrpcBodyToHttp(wreq.Body, wreq.Trailers)
+	req := &http.Request{
+		Method:        method,
+		URL:           u,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        incomingHeader(wreq.Headers, trailer),
+		Body:          body,
+		ContentLength: -1,
+		Trailer:       trailer,
+		RequestURI:    requestURI,
+	}
+	if wreq.Authority != nil {
+		req.Host = *wreq.Authority
+	}
+	if req.Host == "" {
+		req.Host = req.Header.Get("Host")
+	}
+	req.Header.Del("Host")
+	if contentLength := req.Header.Get("Content-Length"); contentLength != "" {
+		n, err := strconv.ParseInt(contentLength, 10, 64)
+		if err != nil || n < 0 {
+			msg := "invalid content-length: " + contentLength
+			return nil, wasitypes.NewErrorCodeInternalError(&msg)
+		}
+		req.ContentLength = n
+	}
+	return req, nil
+}
+
+// incomingResponseWriter streams the response written by an http.Handler through a pipe. The
+// status and headers are available once headerSent is closed, the trailers once done is.
+type incomingResponseWriter struct {
+	header      http.Header
+	wroteHeader bool
+	// headerSent is closed once the handler wrote the headers, or panicked before that
+	headerSent chan struct{}
+	status     int
+	sentHeader http.Header
+	trailer    http.Header
+	// headerErr is set if the handler panicked before writing the headers, err if it panicked
+	headerErr error
+	err       error
+	// done is closed once the handler returned
+	done chan struct{}
+
+	body *incomingResponseBody
+	pw   *io.PipeWriter
+}
+
+func newIncomingResponseWriter(cancel context.CancelFunc) *incomingResponseWriter {
+	pr, pw := io.Pipe()
+	return &incomingResponseWriter{
+		header:     make(http.Header),
+		headerSent: make(chan struct{}),
+		done:       make(chan struct{}),
+		trailer:    make(http.Header),
+		body:       &incomingResponseBody{PipeReader: pr, cancel: cancel},
+		pw:         pw,
+	}
+}
+
+var _ http.Flusher = (*incomingResponseWriter)(nil)
+
+func (w *incomingResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *incomingResponseWriter) WriteHeader(status int) {
+	// Informational responses can't be sent over wRPC
+	if w.wroteHeader || (status >= 100 && status < 200) {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	w.sentHeader = w.header.Clone()
+	close(w.headerSent)
+}
+
+func (w *incomingResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(p)
+}
+
+// Flush sends the headers if they weren't yet, the body is never buffered.
+func (w *incomingResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *incomingResponseWriter) serve(handler http.Handler, req *http.Request) {
+	defer close(w.done)
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(error); ok {
+				w.err = fmt.Errorf("http handler panicked: %w", err)
+			} else {
+				w.err = fmt.Errorf("http handler panicked: %v", r)
+			}
+			if !w.wroteHeader {
+				w.headerErr = w.err
+				close(w.headerSent)
+			}
+			w.pw.CloseWithError(w.err)
+			w.body.cancel()
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.collectTrailers()
+		w.pw.Close()
+	}()
+	handler.ServeHTTP(w, req)
+}
+
+// collectTrailers copies the trailers declared in the Trailer header, or set with the
+// http.TrailerPrefix, once the handler returns.
+func (w *incomingResponseWriter) collectTrailers() {
+	for _, declared := range w.sentHeader.Values("Trailer") {
+		for _, key := range strings.Split(declared, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if values, ok := w.header[key]; ok {
+				w.trailer[key] = values
+			}
+		}
+	}
+	for key, values := range w.header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			w.trailer[http.CanonicalHeaderKey(strings.TrimPrefix(key, http.TrailerPrefix))] = values
+		}
+	}
+}
+
+// incomingResponseBody cancels the request once the response body is closed, whether the
+// caller read it to the end or stopped early.
+type incomingResponseBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (b *incomingResponseBody) Close() error {
+	err := b.PipeReader.Close()
+	b.cancel()
+	return err
+}
+
+// incomingResponseTrailers receives the trailers of a response once its handler returned.
+type incomingResponseTrailers struct {
+	w *incomingResponseWriter
+}
+
+func (t incomingResponseTrailers) Receive() ([]*wrpc.Tuple2[string, [][]byte], error) {
+	<-t.w.done
+	if t.w.err != nil {
+		return nil, t.w.err
+	}
+	return HttpHeaderToWrpc(t.w.trailer), nil
+}
+
+func (t incomingResponseTrailers) Close() error {
+	return nil
+}
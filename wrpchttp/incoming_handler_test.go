@@ -0,0 +1,165 @@
+package wrpchttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+)
+
+func TestIncomingHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, got := http.MethodPost, r.Method; want != got {
+			t.Errorf("expected method %s, got %s", want, got)
+		}
+		if want, got := "/path", r.URL.Path; want != got {
+			t.Errorf("expected path %s, got %s", want, got)
+		}
+		if want, got := "val", r.URL.Query().Get("q"); want != got {
+			t.Errorf("expected query value %s, got %s", want, got)
+		}
+		if want, got := "example.com", r.Host; want != got {
+			t.Errorf("expected host %s, got %s", want, got)
+		}
+		if want, got := "x-client-value", r.Header.Get("X-Client-Custom"); want != got {
+			t.Errorf("expected header value %s, got %s", want, got)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if want, got := "hello request", string(body); want != got {
+			t.Errorf("expected body %s, got %s", want, got)
+		}
+		if want, got := "request-checksum", r.Trailer.Get("X-Checksum"); want != got {
+			t.Errorf("expected request trailer %s, got %s", want, got)
+		}
+
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("X-Custom", "x-value")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello "))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("response"))
+		w.Header().Set("X-Checksum", "response-checksum")
+		w.Header().Set(http.TrailerPrefix+"X-Extra", "extra")
+	})
+
+	wreq := newOutgoingRequest(http.MethodPost, "example.com", "/path?q=val", "hello request", http.Header{"X-Checksum": []string{"request-checksum"}})
+	result, err := NewIncomingHandler(handler).Handle(context.Background(), wreq)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err != nil {
+		t.Fatalf("unexpected error code %v", result.Err)
+	}
+
+	resp := result.Ok
+	if want, got := uint16(http.StatusCreated), resp.Status; want != got {
+		t.Errorf("expected status code %v, got %v", want, got)
+	}
+	if want, got := "x-value", WrpcHeaderToHttp(resp.Headers).Get("X-Custom"); want != got {
+		t.Errorf("expected header value %v, got %v", want, got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want, got := "hello response", string(body); want != got {
+		t.Errorf("expected body %v, got %v", want, got)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	trailers, err := resp.Trailers.Receive()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	trailer := WrpcHeaderToHttp(trailers)
+	if trailer.Get("X-Checksum") != "response-checksum" || trailer.Get("X-Extra") != "extra" {
+		t.Errorf("unexpected response trailers %v", trailer)
+	}
+}
+
+func TestIncomingHandlerCancellation(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	wreq := newOutgoingRequest(http.MethodGet, "example.com", "/", "", nil)
+	result, err := NewIncomingHandler(handler).Handle(context.Background(), wreq)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want, got := uint16(http.StatusOK), result.Ok.Status; want != got {
+		t.Errorf("expected status code %v, got %v", want, got)
+	}
+
+	// The caller stops reading the response
+	if err := result.Ok.Body.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the request context to be cancelled")
+	}
+
+	// The invocation is cancelled while the handler writes the body
+	ctx, cancel := context.WithCancel(context.Background())
+	written := make(chan error, 1)
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		_, err := w.Write([]byte("too late"))
+		written <- err
+	}
+	if _, err := NewIncomingHandler(handler).Handle(ctx, wreq); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cancel()
+	select {
+	case err := <-written:
+		if err == nil {
+			t.Error("expected the write to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the handler to be unblocked")
+	}
+}
+
+func TestIncomingHandlerPanic(t *testing.T) {
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	wreq := newOutgoingRequest(http.MethodGet, "example.com", "/", "", nil)
+	result, err := NewIncomingHandler(handler).Handle(context.Background(), wreq)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err == nil || result.Err.Discriminant() != wasitypes.ErrorCodeInternalError {
+		t.Fatalf("expected an internal error, got %v", result.Err)
+	}
+	if msg, _ := result.Err.GetInternalError(); msg == nil || !strings.Contains(*msg, "boom") {
+		t.Errorf("expected the panic in the error message, got %v", msg)
+	}
+
+	handler = func(http.ResponseWriter, *http.Request) {
+		panic(ErrLoopDetected)
+	}
+	result, err = NewIncomingHandler(handler).Handle(context.Background(), wreq)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err == nil || result.Err.Discriminant() != wasitypes.ErrorCodeLoopDetected {
+		t.Errorf("expected a loop detected error, got %v", result.Err)
+	}
+}
@@ -3,10 +3,18 @@ package wrpchttp
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"runtime"
+	"strconv"
 	"testing"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
 	"go.wasmcloud.dev/provider/internal/wrpc/http/incoming_handler"
 	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
@@ -102,12 +110,33 @@ func (fakeReceiver) Close() error {
 	return nil
 }
 
+// checkGoroutineLeaks fails the test if goroutines started during it still run once it ends.
+func checkGoroutineLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				t.Errorf("leaked %d goroutines:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
 func TestRoundtrip(t *testing.T) {
+	checkGoroutineLeaks(t)
 	reqBody := "hello request"
 	respBody := "hello response"
 	pathWithQuery := "/path?q=val"
 	req, _ := http.NewRequest(http.MethodPost, "http://example.com"+pathWithQuery, bytes.NewReader([]byte(reqBody)))
 	req.Header.Add("X-Client-Custom", "x-client-value")
+	req.Header.Add("Connection", "X-Hop")
+	req.Header.Add("X-Hop", "hop")
+	req.Header.Add("Keep-Alive", "timeout=5")
 
 	wrpcTarget := "component_id"
 	fakeNc := fakeNatsCreator{
@@ -136,20 +165,18 @@ func TestRoundtrip(t *testing.T) {
 			t.Errorf("expected method %d, got %d", want, got)
 		}
 
-		if want, got := 1, len(wrpcReq.Headers); want != got {
+		// Hop-by-hop headers are removed and the body length is set
+		header := WrpcHeaderToHttp(wrpcReq.Headers)
+		if want, got := 2, len(header); want != got {
 			t.Fatalf("expected %v headers, got %v", want, got)
 		}
 
-		if want, got := "X-Client-Custom", wrpcReq.Headers[0].V0; want != got {
-			t.Fatalf("expected header %v, got %v", want, got)
+		if want, got := []string{"x-client-value"}, header.Values("X-Client-Custom"); len(got) != 1 || want[0] != got[0] {
+			t.Fatalf("expected header values %v, got %v", want, got)
 		}
 
-		if want, got := 1, len(wrpcReq.Headers[0].V1); want != got {
-			t.Fatalf("expected %v header values, got %v", want, got)
-		}
-
-		if want, got := "x-client-value", string(wrpcReq.Headers[0].V1[0]); want != got {
-			t.Fatalf("expected header value %v, got %v", want, got)
+		if want, got := strconv.Itoa(len(reqBody)), header.Get("Content-Length"); want != got {
+			t.Errorf("expected content length %v, got %v", want, got)
 		}
 
 		body, err := io.ReadAll(wrpcReq.Body)
@@ -161,10 +188,15 @@ func TestRoundtrip(t *testing.T) {
 		}
 
 		resp := wrpctypes.Response{
-			Status:   http.StatusOK,
-			Headers:  HttpHeaderToWrpc(http.Header{"X-Custom": []string{"x-value"}}),
+			Status: http.StatusOK,
+			Headers: HttpHeaderToWrpc(http.Header{
+				"X-Custom":          []string{"x-value"},
+				"Content-Length":    []string{strconv.Itoa(len(respBody))},
+				"Trailer":           []string{"X-Checksum"},
+				"Transfer-Encoding": []string{"chunked"},
+			}),
 			Body:     io.NopCloser(bytes.NewReader([]byte(respBody))),
-			Trailers: fakeReceiver{headers: http.Header{}},
+			Trailers: fakeReceiver{headers: http.Header{"X-Checksum": []string{"response-checksum"}}},
 		}
 
 		errCh := make(chan error)
@@ -185,6 +217,18 @@ func TestRoundtrip(t *testing.T) {
 		t.Errorf("expected status code %v, got %v", want, got)
 	}
 
+	if want, got := int64(len(respBody)), resp.ContentLength; want != got {
+		t.Errorf("expected content length %v, got %v", want, got)
+	}
+
+	if got := resp.Header.Get("Transfer-Encoding") + resp.Header.Get("Trailer"); got != "" {
+		t.Errorf("expected the hop-by-hop and trailer headers to be removed, got %v", resp.Header)
+	}
+
+	if _, ok := resp.Trailer["X-Checksum"]; !ok {
+		t.Errorf("expected the trailer to be announced, got %v", resp.Trailer)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		t.Fatalf("unexpected error %v", err)
@@ -193,7 +237,223 @@ func TestRoundtrip(t *testing.T) {
 		t.Errorf("expected body %v, got %v", want, got)
 	}
 
+	if want, got := "response-checksum", resp.Trailer.Get("X-Checksum"); want != got {
+		t.Errorf("expected response trailer %v, got %v", want, got)
+	}
+
 	if want, got := error(nil), resp.Body.Close(); want != got {
 		t.Errorf("expected body.Close() to return %v, got %v", want, got)
 	}
 }
+
+// newStreamingRoundTripper returns a round tripper responding with body. The writes of the
+// request are started with write, and the invocation context is sent to invoked.
+func newStreamingRoundTripper(body io.ReadCloser, write func(*wrpctypes.Request) <-chan error, invoked chan<- context.Context) *IncomingRoundTripper {
+	fakeNc := fakeNatsCreator{
+		OutgoingRpcClientFunc: func(string) *wrpcnats.Client { return nil },
+	}
+	roundTripper := NewIncomingRoundTripper(fakeNc, WithSingleTarget("component_id"))
+	roundTripper.invoker = func(ctx context.Context, _ wrpc.Invoker, wreq *wrpctypes.Request) (*wrpc.Result[incoming_handler.Response, incoming_handler.ErrorCode], <-chan error, error) {
+		invoked <- ctx
+		return wrpc.Ok[incoming_handler.ErrorCode](wrpctypes.Response{
+			Status:   http.StatusOK,
+			Body:     body,
+			Trailers: fakeReceiver{headers: http.Header{}},
+		}), write(wreq), nil
+	}
+	return roundTripper
+}
+
+func TestRoundtripBodyClose(t *testing.T) {
+	checkGoroutineLeaks(t)
+
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	invoked := make(chan context.Context, 1)
+	roundTripper := newStreamingRoundTripper(body, func(wreq *wrpctypes.Request) <-chan error {
+		errCh := make(chan error)
+		go func() {
+			_, _ = io.Copy(io.Discard, wreq.Body)
+			close(errCh)
+		}()
+		return errCh
+	}, invoked)
+
+	// The request body never ends, it must be closed with the response
+	reqBody, _ := io.Pipe()
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", reqBody)
+	resp, err := roundTripper.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ctx := <-invoked
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		readErr <- err
+	}()
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, errBodyClosed) {
+			t.Errorf("expected a closed body error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pending read to return")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the invocation to be cancelled")
+	}
+}
+
+func TestRoundtripContextCancel(t *testing.T) {
+	checkGoroutineLeaks(t)
+
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	invoked := make(chan context.Context, 1)
+	roundTripper := newStreamingRoundTripper(body, func(*wrpctypes.Request) <-chan error {
+		return nil
+	}, invoked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/", nil)
+	resp, err := roundTripper.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	invocationCtx := <-invoked
+
+	go func() {
+		_, _ = bodyWriter.Write([]byte("partial"))
+		cancel()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if want, got := "partial", string(data); want != got {
+		t.Errorf("expected body %v, got %v", want, got)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled error, got %v", err)
+	}
+	if err := invocationCtx.Err(); err == nil {
+		t.Error("expected the invocation to be cancelled")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRoundtripWriteError(t *testing.T) {
+	checkGoroutineLeaks(t)
+
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	invoked := make(chan context.Context, 1)
+	roundTripper := newStreamingRoundTripper(body, func(*wrpctypes.Request) <-chan error {
+		errCh := make(chan error, 1)
+		errCh <- errors.New("failed to send the body")
+		close(errCh)
+		return errCh
+	}, invoked)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", bytes.NewReader([]byte("hello")))
+	resp, err := roundTripper.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	<-invoked
+
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrRPC) {
+		t.Errorf("expected an rpc error, got %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// fakeIndexReader serves the result of an invocation, its nested readers serving nested.
+type fakeIndexReader struct {
+	*bytes.Reader
+	nested string
+}
+
+func (r *fakeIndexReader) Index(...uint32) (wrpc.IndexReadCloser, error) {
+	return &fakeIndexReader{Reader: bytes.NewReader([]byte(r.nested))}, nil
+}
+
+func (r *fakeIndexReader) Close() error {
+	return nil
+}
+
+type fakeIndexInvoker struct {
+	body string
+}
+
+func (i fakeIndexInvoker) Invoke(context.Context, string, string, []byte, ...wrpc.SubscribePath) (wrpc.IndexWriteCloser, wrpc.IndexReadCloser, error) {
+	return nil, &fakeIndexReader{Reader: bytes.NewReader(nil), nested: i.body}, nil
+}
+
+func TestRoundtripInstrumentation(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	fakeNc := fakeNatsCreator{
+		OutgoingRpcClientFunc: func(string) *wrpcnats.Client { return nil },
+	}
+	roundTripper := NewIncomingRoundTripper(fakeNc, WithSingleTarget("component_id"))
+	roundTripper.client = func(string) wrpc.Invoker {
+		return fakeIndexInvoker{body: "hello response"}
+	}
+	// Like the generated bindings, close the result reader once the response is decoded,
+	// leaving the body stream open
+	roundTripper.invoker = func(ctx context.Context, invoker wrpc.Invoker, _ *wrpctypes.Request) (*wrpc.Result[incoming_handler.Response, incoming_handler.ErrorCode], <-chan error, error) {
+		_, r, err := invoker.Invoke(ctx, "wrpc:http/incoming-handler@0.1.0", "handle", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer r.Close()
+		body, err := r.Index(0, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wrpc.Ok[incoming_handler.ErrorCode](wrpctypes.Response{
+			Status:   http.StatusOK,
+			Body:     body,
+			Trailers: fakeReceiver{headers: http.Header{}},
+		}), nil, nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	resp, err := roundTripper.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ended := spans.Ended(); len(ended) != 0 {
+		t.Fatalf("expected the span to last until the body is read, got %d ended spans", len(ended))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want, got := "hello response", string(body); want != got {
+		t.Errorf("expected body %s, got %s", want, got)
+	}
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(ended))
+	}
+	if want, got := "wrpc:http/incoming-handler@0.1.0/handle", ended[0].Name(); want != got {
+		t.Errorf("expected span %s, got %s", want, got)
+	}
+	if ended[0].SpanKind() != trace.SpanKindClient {
+		t.Errorf("expected a client span, got %v", ended[0].SpanKind())
+	}
+}
@@ -0,0 +1,270 @@
+package wrpchttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.wasmcloud.dev/provider/internal/exports/wrpc/http/outgoing_handler"
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
+
+	wrpc "wrpc.io/go"
+)
+
+var (
+	errConnectTimeout      = errors.New("connect timeout")
+	errFirstByteTimeout    = errors.New("first byte timeout")
+	errBetweenBytesTimeout = errors.New("between bytes timeout")
+)
+
+// OutgoingHandler implements `wrpc:http/outgoing-handler`, sending the requests of components
+// with an http.RoundTripper.
+type OutgoingHandler struct {
+	transport http.RoundTripper
+}
+
+var _ outgoing_handler.Handler = (*OutgoingHandler)(nil)
+
+type OutgoingHandlerOption func(*OutgoingHandler)
+
+// WithTransport sets the http.RoundTripper sending the requests, http.DefaultTransport by default.
+func WithTransport(transport http.RoundTripper) OutgoingHandlerOption {
+	return func(h *OutgoingHandler) {
+		h.transport = transport
+	}
+}
+
+func NewOutgoingHandler(opts ...OutgoingHandlerOption) *OutgoingHandler {
+	h := &OutgoingHandler{
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Serve serves `wrpc:http/outgoing-handler` on s, usually the provider's RPCServer. It can be
+// passed to provider.Run. The returned function stops serving.
+func (h *OutgoingHandler) Serve(s wrpc.Server) (func() error, error) {
+	return outgoing_handler.ServeInterface(s, h)
+}
+
+// Handle sends the request of a component. Failures to send the request are returned as the
+// error-code of the result. The connect and first byte timeouts of the request options rely on
+// httptrace, which http.Transport supports. The between bytes timeout applies to the reads of
+// the response body.
+func (h *OutgoingHandler) Handle(ctx context.Context, wreq *wrpctypes.Request, options *wrpctypes.RequestOptions) (*wrpc.Result[outgoing_handler.Response, outgoing_handler.ErrorCode], error) {
+	req, errCode := WrpcRequestToHttp(wreq)
+	if errCode != nil {
+		return wrpc.Err[outgoing_handler.Response](*errCode), nil
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timeouts := newRequestTimeouts(options, cancel)
+	if trace := timeouts.clientTrace(); trace != nil {
+		ctx = httptrace.WithClientTrace(ctx, trace)
+	}
+
+	resp, err := h.transport.RoundTrip(req.WithContext(ctx))
+	timeouts.stop()
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			err = cause
+		}
+		cancel(nil)
+		return wrpc.Err[outgoing_handler.Response](*ErrorCodeFromError(err)), nil
+	}
+
+	body := &outgoingResponseBody{
+		body:    resp.Body,
+		cancel:  cancel,
+		timeout: timeouts.betweenBytes,
+	}
+	outgoingBodyTrailer := HttpBodyToWrpc(body, resp.Trailer)
+	return wrpc.Ok[outgoing_handler.ErrorCode](outgoing_handler.Response{
+		Status:   uint16(resp.StatusCode),
+		Headers:  HttpHeaderToWrpc(outgoingHeader(resp.Header, resp.Trailer, resp.ContentLength)),
+		Body:     outgoingBodyTrailer,
+		Trailers: outgoingBodyTrailer,
+	}), nil
+}
+
+// WrpcRequestToHttp converts the request of a component to an outgoing http.Request. Its
+// hop-by-hop headers are removed, and its trailers are set once the body is read. Invalid
+// requests are reported as an error-code.
+func WrpcRequestToHttp(wreq *wrpctypes.Request) (*http.Request, *wrpctypes.ErrorCode) {
+	if wreq.Authority == nil || *wreq.Authority == "" {
+		return nil, wasitypes.NewErrorCodeHttpRequestUriInvalid()
+	}
+	scheme := "http"
+	if wreq.Scheme != nil {
+		scheme = WrpcSchemeToHttp(wreq.Scheme)
+	}
+	pathWithQuery := "/"
+	if wreq.PathWithQuery != nil && *wreq.PathWithQuery != "" {
+		pathWithQuery = *wreq.PathWithQuery
+	}
+	u, err := url.Parse(scheme + "://" + *wreq.Authority + pathWithQuery)
+	if err != nil {
+		return nil, wasitypes.NewErrorCodeHttpRequestUriInvalid()
+	}
+	method := WrpcMethodToHttp(wreq.Method)
+	if method == "" {
+		return nil, wasitypes.NewErrorCodeHttpRequestMethodInvalid()
+	}
+
+	body, trailer := WrpcBodyToHttp(wreq.Body, wreq.Trailers)
+	req := &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     incomingHeader(wreq.Headers, trailer),
+		Host:       u.Host,
+		Body:       body,
+		Trailer:    trailer,
+	}
+	req.Header.Del("Host")
+	if contentLength := req.Header.Get("Content-Length"); contentLength != "" {
+		n, err := strconv.ParseInt(contentLength, 10, 64)
+		if err != nil || n < 0 {
+			msg := "invalid content-length: " + contentLength
+			return nil, wasitypes.NewErrorCodeInternalError(&msg)
+		}
+		req.ContentLength = n
+		req.Header.Del("Content-Length")
+	} else if bodylessMethod(method) && len(trailer) == 0 {
+		// Don't send an empty chunked body, which some servers reject
+		body.Close()
+		req.Body = http.NoBody
+	} else {
+		// The body is streamed, its length is unknown
+		req.ContentLength = -1
+	}
+	return req, nil
+}
+
+// bodylessMethod reports whether requests with method have no body, unless they declare
+// one with their headers.
+func bodylessMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// requestTimeouts enforces the connect and first byte timeouts of a request, cancelling it
+// with the timeout as cause.
+type requestTimeouts struct {
+	connect      time.Duration
+	firstByte    time.Duration
+	betweenBytes time.Duration
+	cancel       context.CancelCauseFunc
+
+	lock    sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+func newRequestTimeouts(options *wrpctypes.RequestOptions, cancel context.CancelCauseFunc) *requestTimeouts {
+	t := &requestTimeouts{cancel: cancel}
+	if options == nil {
+		return t
+	}
+	if options.ConnectTimeout != nil {
+		t.connect = time.Duration(*options.ConnectTimeout)
+	}
+	if options.FirstByteTimeout != nil {
+		t.firstByte = time.Duration(*options.FirstByteTimeout)
+	}
+	if options.BetweenBytesTimeout != nil {
+		t.betweenBytes = time.Duration(*options.BetweenBytesTimeout)
+	}
+	return t
+}
+
+func (t *requestTimeouts) clientTrace() *httptrace.ClientTrace {
+	if t.connect <= 0 && t.firstByte <= 0 {
+		return nil
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.start(t.connect, errConnectTimeout)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			t.start(t.firstByte, errFirstByteTimeout)
+		},
+		GotFirstResponseByte: func() {
+			t.start(0, nil)
+		},
+	}
+}
+
+// start replaces the running timer, if any, with one cancelling the request with cause after d.
+func (t *requestTimeouts) start(d time.Duration, cause error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	if t.stopped || d <= 0 {
+		return
+	}
+	t.timer = time.AfterFunc(d, func() {
+		t.cancel(cause)
+	})
+}
+
+// stop stops the running timer, once the response headers are received.
+func (t *requestTimeouts) stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.stopped = true
+}
+
+// outgoingResponseBody applies the between bytes timeout to the reads of a response body and
+// releases the request once closed.
+type outgoingResponseBody struct {
+	body    io.ReadCloser
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+	expired atomic.Bool
+}
+
+func (b *outgoingResponseBody) Read(p []byte) (int, error) {
+	if b.timeout <= 0 {
+		return b.body.Read(p)
+	}
+
+	timer := time.AfterFunc(b.timeout, func() {
+		b.expired.Store(true)
+		b.cancel(errBetweenBytesTimeout)
+	})
+	n, err := b.body.Read(p)
+	timer.Stop()
+	if err != nil && err != io.EOF && b.expired.Load() {
+		err = errBetweenBytesTimeout
+	}
+	return n, err
+}
+
+func (b *outgoingResponseBody) Close() error {
+	err := b.body.Close()
+	b.cancel(nil)
+	return err
+}
@@ -0,0 +1,187 @@
+package wrpchttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
+)
+
+func newOutgoingRequest(method string, authority string, pathWithQuery string, body string, trailers http.Header) *wrpctypes.Request {
+	return &wrpctypes.Request{
+		Method:        HttpMethodToWrpc(method),
+		Scheme:        HttpSchemeToWrpc("http"),
+		Authority:     &authority,
+		PathWithQuery: &pathWithQuery,
+		Headers:       HttpHeaderToWrpc(http.Header{"X-Client-Custom": []string{"x-client-value"}}),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		Trailers:      fakeReceiver{headers: trailers},
+	}
+}
+
+func TestOutgoingHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if want, got := "/path?q=val", r.URL.RequestURI(); want != got {
+			t.Errorf("expected request uri %s, got %s", want, got)
+		}
+		if want, got := "hello request", string(body); want != got {
+			t.Errorf("expected body %s, got %s", want, got)
+		}
+		if want, got := "x-client-value", r.Header.Get("X-Client-Custom"); want != got {
+			t.Errorf("expected header value %s, got %s", want, got)
+		}
+		if want, got := "request-checksum", r.Trailer.Get("X-Checksum"); want != got {
+			t.Errorf("expected request trailer %s, got %s", want, got)
+		}
+
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("X-Custom", "x-value")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello response"))
+		w.Header().Set("X-Checksum", "response-checksum")
+	}))
+	defer server.Close()
+
+	authority := strings.TrimPrefix(server.URL, "http://")
+	wreq := newOutgoingRequest(http.MethodPost, authority, "/path?q=val", "hello request", http.Header{"X-Checksum": []string{"request-checksum"}})
+	result, err := NewOutgoingHandler().Handle(context.Background(), wreq, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err != nil {
+		t.Fatalf("unexpected error code %v", result.Err)
+	}
+
+	resp := result.Ok
+	if want, got := uint16(http.StatusCreated), resp.Status; want != got {
+		t.Errorf("expected status code %v, got %v", want, got)
+	}
+	if want, got := "x-value", WrpcHeaderToHttp(resp.Headers).Get("X-Custom"); want != got {
+		t.Errorf("expected header value %v, got %v", want, got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want, got := "hello response", string(body); want != got {
+		t.Errorf("expected body %v, got %v", want, got)
+	}
+	trailers, err := resp.Trailers.Receive()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want, got := "response-checksum", WrpcHeaderToHttp(trailers).Get("X-Checksum"); want != got {
+		t.Errorf("expected response trailer %v, got %v", want, got)
+	}
+}
+
+func TestOutgoingHandlerTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-body" {
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	authority := strings.TrimPrefix(server.URL, "http://")
+
+	timeout := uint64(50 * time.Millisecond)
+	handler := NewOutgoingHandler()
+	result, err := handler.Handle(context.Background(),
+		newOutgoingRequest(http.MethodGet, authority, "/slow-headers", "", nil),
+		&wrpctypes.RequestOptions{FirstByteTimeout: &timeout})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err == nil || result.Err.Discriminant() != wasitypes.ErrorCodeConnectionReadTimeout {
+		t.Errorf("expected a connection read timeout, got %v", result.Err)
+	}
+
+	result, err = handler.Handle(context.Background(),
+		newOutgoingRequest(http.MethodGet, authority, "/slow-body", "", nil),
+		&wrpctypes.RequestOptions{BetweenBytesTimeout: &timeout})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err != nil {
+		t.Fatalf("unexpected error code %v", result.Err)
+	}
+	body, err := io.ReadAll(result.Ok.Body)
+	if string(body) != "partial" || err != errBetweenBytesTimeout {
+		t.Errorf("expected the body to time out after the first bytes, got %q, %v", body, err)
+	}
+
+	// The connection never completes
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	result, err = NewOutgoingHandler(WithTransport(transport)).Handle(context.Background(),
+		newOutgoingRequest(http.MethodGet, "example.com", "/", "", nil),
+		&wrpctypes.RequestOptions{ConnectTimeout: &timeout})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err == nil || result.Err.Discriminant() != wasitypes.ErrorCodeConnectionTimeout {
+		t.Errorf("expected a connection timeout, got %v", result.Err)
+	}
+}
+
+func TestOutgoingHandlerInvalidRequest(t *testing.T) {
+	wreq := newOutgoingRequest(http.MethodGet, "", "/", "", nil)
+	result, err := NewOutgoingHandler().Handle(context.Background(), wreq, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Err == nil || result.Err.Discriminant() != wasitypes.ErrorCodeHttpRequestUriInvalid {
+		t.Errorf("expected an invalid uri error, got %v", result.Err)
+	}
+}
+
+func TestWrpcRequestToHttpBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		header        http.Header
+		noBody        bool
+		contentLength int64
+	}{
+		{name: "get", method: http.MethodGet, noBody: true},
+		{name: "get with length", method: http.MethodGet, header: http.Header{"Content-Length": []string{"5"}}, contentLength: 5},
+		{name: "get with trailers", method: http.MethodGet, header: http.Header{"Trailer": []string{"X-Checksum"}}, contentLength: -1},
+		{name: "post", method: http.MethodPost, contentLength: -1},
+		{name: "post with length", method: http.MethodPost, header: http.Header{"Content-Length": []string{"5"}}, contentLength: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wreq := newOutgoingRequest(tt.method, "example.com", "/", "hello", nil)
+			wreq.Headers = HttpHeaderToWrpc(tt.header)
+			req, errCode := WrpcRequestToHttp(wreq)
+			if errCode != nil {
+				t.Fatalf("unexpected error code %v", errCode)
+			}
+			if want, got := tt.noBody, req.Body == http.NoBody; want != got {
+				t.Errorf("expected no body %v, got %v", want, got)
+			}
+			if want, got := tt.contentLength, req.ContentLength; want != got {
+				t.Errorf("expected content length %d, got %d", want, got)
+			}
+		})
+	}
+}
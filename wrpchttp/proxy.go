@@ -0,0 +1,183 @@
+package wrpchttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+)
+
+// ReverseProxy is an http.Handler forwarding requests to components with an
+// IncomingRoundTripper, or any other http.RoundTripper, like httputil.ReverseProxy does.
+// Hop-by-hop headers are removed, the X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto
+// headers are set, and the response body is flushed as it's received so it can be streamed.
+// Informational responses and trailers, announced or not, are forwarded.
+type ReverseProxy struct {
+	transport    http.RoundTripper
+	errorHandler func(http.ResponseWriter, *http.Request, error)
+}
+
+var _ http.Handler = (*ReverseProxy)(nil)
+
+type ReverseProxyOption func(*ReverseProxy)
+
+// WithErrorHandler sets the function replying to the requests failing to be forwarded. By
+// default the error is logged with the default slog logger, and the proxy replies with 504
+// Gateway Timeout on timeouts, and 502 Bad Gateway otherwise.
+func WithErrorHandler(errorHandler func(http.ResponseWriter, *http.Request, error)) ReverseProxyOption {
+	return func(p *ReverseProxy) {
+		p.errorHandler = errorHandler
+	}
+}
+
+func NewReverseProxy(transport http.RoundTripper, opts ...ReverseProxyOption) *ReverseProxy {
+	p := &ReverseProxy{
+		transport:    transport,
+		errorHandler: defaultProxyErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func defaultProxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "http: proxy error", slog.Any("error", err))
+	if errors.Is(err, context.DeadlineExceeded) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			// Switching protocols isn't supported
+			if code == http.StatusSwitchingProtocols {
+				return nil
+			}
+			h := w.Header()
+			copyHeader(h, http.Header(header))
+			w.WriteHeader(code)
+			clear(h)
+			return nil
+		},
+	})
+	outreq := r.Clone(ctx)
+	if r.ContentLength == 0 {
+		outreq.Body = nil
+	}
+	outreq.RequestURI = ""
+	outreq.Close = false
+	if outreq.Header == nil {
+		outreq.Header = make(http.Header)
+	}
+	removeHopByHopHeaders(outreq.Header)
+	// Te: trailers is the only value end-to-end
+	if httpHeaderContainsToken(r.Header, "Te", "trailers") {
+		outreq.Header.Set("Te", "trailers")
+	}
+	setForwardedHeaders(outreq, r)
+
+	resp, err := p.transport.RoundTrip(outreq)
+	if err != nil {
+		p.errorHandler(w, r, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopByHopHeaders(resp.Header)
+	copyHeader(w.Header(), resp.Header)
+	announced := make(map[string]bool, len(resp.Trailer))
+	if len(resp.Trailer) > 0 {
+		keys := make([]string, 0, len(resp.Trailer))
+		for key := range resp.Trailer {
+			keys = append(keys, key)
+			announced[key] = true
+		}
+		w.Header().Set("Trailer", strings.Join(keys, ", "))
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	rc := http.NewResponseController(w)
+	_ = rc.Flush()
+	if err := copyFlushing(w, rc, resp.Body); err != nil {
+		// Abort the response rather than returning a truncated body
+		panic(http.ErrAbortHandler)
+	}
+
+	// Trailers not announced are only known once the body is read
+	for key, values := range resp.Trailer {
+		if !announced[key] {
+			key = http.TrailerPrefix + key
+		}
+		w.Header()[key] = values
+	}
+}
+
+// setForwardedHeaders sets the X-Forwarded-* headers of the forwarded request outreq. Like
+// httputil.ReverseProxy, X-Forwarded-For isn't set if it's present in the header with a nil value.
+func setForwardedHeaders(outreq *http.Request, r *http.Request) {
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		prior, ok := outreq.Header["X-Forwarded-For"]
+		if !ok || prior != nil {
+			if len(prior) > 0 {
+				clientIP = strings.Join(prior, ", ") + ", " + clientIP
+			}
+			outreq.Header.Set("X-Forwarded-For", clientIP)
+		}
+	}
+	outreq.Header.Set("X-Forwarded-Host", r.Host)
+	if r.TLS != nil {
+		outreq.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		outreq.Header.Set("X-Forwarded-Proto", "http")
+	}
+}
+
+// copyFlushing copies body to w, flushing after each write.
+func copyFlushing(w io.Writer, rc *http.ResponseController, body io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			_ = rc.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func copyHeader(dst http.Header, src http.Header) {
+	for key, values := range src {
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
+
+// httpHeaderContainsToken reports whether the comma separated values of the header key contain
+// token, ignoring case.
+func httpHeaderContainsToken(h http.Header, key string, token string) bool {
+	for _, field := range h.Values(key) {
+		for _, value := range strings.Split(field, ",") {
+			if strings.EqualFold(textproto.TrimString(value), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package wrpchttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	wasitypes "go.wasmcloud.dev/provider/internal/wasi/http/types"
+	"go.wasmcloud.dev/provider/internal/wrpc/http/incoming_handler"
+	wrpctypes "go.wasmcloud.dev/provider/internal/wrpc/http/types"
+	wrpc "wrpc.io/go"
+	wrpcnats "wrpc.io/go/nats"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newHandlerRoundTripper returns a round tripper invoking handler through an IncomingHandler,
+// without wRPC.
+func newHandlerRoundTripper(handler http.Handler) *IncomingRoundTripper {
+	fakeNc := fakeNatsCreator{
+		OutgoingRpcClientFunc: func(string) *wrpcnats.Client { return nil },
+	}
+	roundTripper := NewIncomingRoundTripper(fakeNc, WithSingleTarget("component_id"))
+	roundTripper.invoker = func(ctx context.Context, _ wrpc.Invoker, wreq *wrpctypes.Request) (*wrpc.Result[incoming_handler.Response, incoming_handler.ErrorCode], <-chan error, error) {
+		result, err := NewIncomingHandler(handler).Handle(ctx, wreq)
+		errCh := make(chan error)
+		close(errCh)
+		return result, errCh, err
+	}
+	return roundTripper
+}
+
+func TestReverseProxy(t *testing.T) {
+	firstChunk := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Hop"); got != "" {
+			t.Errorf("expected the hop-by-hop header to be removed, got %s", got)
+		}
+		if want, got := "127.0.0.1", r.Header.Get("X-Forwarded-For"); want != got {
+			t.Errorf("expected forwarded for %s, got %s", want, got)
+		}
+		if want, got := "http", r.Header.Get("X-Forwarded-Proto"); want != got {
+			t.Errorf("expected forwarded proto %s, got %s", want, got)
+		}
+		if r.Header.Get("X-Forwarded-Host") == "" || r.Header.Get("X-Forwarded-Host") != r.Host {
+			t.Errorf("expected forwarded host %s, got %s", r.Host, r.Header.Get("X-Forwarded-Host"))
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if want, got := "hello request", string(body); want != got {
+			t.Errorf("expected body %s, got %s", want, got)
+		}
+
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Connection", "X-Response-Hop")
+		w.Header().Set("X-Response-Hop", "hop")
+		w.Header().Set("X-Custom", "x-value")
+		_, _ = w.Write([]byte("hello "))
+		w.(http.Flusher).Flush()
+		// The first chunk is streamed before the response ends
+		<-firstChunk
+		_, _ = w.Write([]byte("response"))
+		w.Header().Set("X-Checksum", "response-checksum")
+		w.Header().Set(http.TrailerPrefix+"X-Extra", "extra")
+	})
+	server := httptest.NewServer(NewReverseProxy(newHandlerRoundTripper(backend)))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/path", strings.NewReader("hello request"))
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "hop")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer resp.Body.Close()
+
+	if want, got := http.StatusOK, resp.StatusCode; want != got {
+		t.Errorf("expected status code %v, got %v", want, got)
+	}
+	if want, got := "x-value", resp.Header.Get("X-Custom"); want != got {
+		t.Errorf("expected header value %v, got %v", want, got)
+	}
+	if got := resp.Header.Get("X-Response-Hop"); got != "" {
+		t.Errorf("expected the hop-by-hop header to be removed, got %v", got)
+	}
+	if _, ok := resp.Trailer["X-Checksum"]; !ok {
+		t.Errorf("expected the trailer to be announced, got %v", resp.Trailer)
+	}
+	chunk := make([]byte, len("hello "))
+	if _, err := io.ReadFull(resp.Body, chunk); err != nil || string(chunk) != "hello " {
+		t.Fatalf("expected the first chunk, got %q, %v", chunk, err)
+	}
+	close(firstChunk)
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want, got := "response", string(rest); want != got {
+		t.Errorf("expected body %v, got %v", want, got)
+	}
+	if resp.Trailer.Get("X-Checksum") != "response-checksum" || resp.Trailer.Get("X-Extra") != "extra" {
+		t.Errorf("unexpected response trailers %v", resp.Trailer)
+	}
+}
+
+func TestReverseProxyInformational(t *testing.T) {
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		trace := httptrace.ContextClientTrace(r.Context())
+		if err := trace.Got1xxResponse(http.StatusEarlyHints, textproto.MIMEHeader{"Link": []string{"</style.css>; rel=preload"}}); err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+	server := httptest.NewServer(NewReverseProxy(transport))
+	defer server.Close()
+
+	var informational []string
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			informational = append(informational, fmt.Sprintf("%d %s", code, header.Get("Link")))
+			return nil
+		},
+	})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer resp.Body.Close()
+
+	if len(informational) != 1 || informational[0] != "103 </style.css>; rel=preload" {
+		t.Errorf("expected the early hints, got %v", informational)
+	}
+	if want, got := http.StatusOK, resp.StatusCode; want != got {
+		t.Errorf("expected status code %v, got %v", want, got)
+	}
+	if got := resp.Header.Get("Link"); got != "" {
+		t.Errorf("expected the early hints headers not to be sent with the response, got %v", got)
+	}
+}
+
+func TestReverseProxyError(t *testing.T) {
+	tt := map[string]struct {
+		err  error
+		want int
+	}{
+		"timeout": {ErrorCodeToError(wasitypes.NewErrorCodeConnectionTimeout()), http.StatusGatewayTimeout},
+		"refused": {ErrorCodeToError(wasitypes.NewErrorCodeConnectionRefused()), http.StatusBadGateway},
+	}
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			logs.Reset()
+			transport := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("%w: %w", ErrRPC, tc.err)
+			})
+			w := httptest.NewRecorder()
+			NewReverseProxy(transport).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if want, got := tc.want, w.Code; want != got {
+				t.Errorf("expected status code %v, got %v", want, got)
+			}
+			if !strings.Contains(logs.String(), "http: proxy error") {
+				t.Errorf("expected the error to be logged, got %q", logs.String())
+			}
+		})
+	}
+}